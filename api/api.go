@@ -10,6 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
 	"github.com/abrander/alerto/monitor"
 	"github.com/abrander/alerto/plugins"
 	"github.com/abrander/alerto/plugins/ssh"
@@ -17,6 +20,7 @@ import (
 
 type (
 	Message struct {
+		Id      string      `json:"id,omitempty"`
 		Type    string      `json:"type"`
 		Payload interface{} `json:"payload"`
 	}
@@ -25,6 +29,7 @@ type (
 		Uptime  time.Duration `json:"uptime"`
 		Clock   time.Time     `json:"clock"`
 		Started time.Time     `json:"start"`
+		Seq     uint64        `json:"seq"`
 	}
 )
 
@@ -40,53 +45,30 @@ func init() {
 	StartTime = time.Now()
 }
 
-func wshandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := wsupgrader.Upgrade(w, r, nil)
-	if err != nil {
-		return
-	}
-
-	ticker := time.Tick(time.Second)
-	changes := monitor.SubscribeChanges()
-
-	status := Status{
-		Started: StartTime,
-	}
-
-	for {
-		select {
-		case t := <-ticker:
-			status.Clock = t
-			status.Uptime = t.Sub(StartTime)
-			err := conn.WriteJSON(Message{Type: "status", Payload: status})
-			if err != nil {
-				goto unsubscribe
-			}
-		case msg := <-changes:
-			err := conn.WriteJSON(msg)
-			if err != nil {
-				goto unsubscribe
-			}
-		}
-	}
-
-unsubscribe:
-	monitor.UnsubscribeChanges(changes)
-}
-
 func Run(wg sync.WaitGroup) {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
 	router.Use(gin.Logger())
+	router.Use(instrument)
 
 	router.Use(static.Serve("/", static.LocalFile("/home/abrander/gocode/src/github.com/abrander/alerto/web/", false)))
 
-	router.GET("/ws", func(c *gin.Context) {
-		wshandler(c.Writer, c.Request)
-	})
-
-	a := router.Group("/agent")
+	authRoutes(router)
+	auditRoutes(router)
+	reportRoutes(router)
+	incidentRoutes(router)
+	statusRoutes(router)
+	badgeRoutes(router)
+	schemaRoutes(router)
+	scriptRoutes(router)
+
+	router.GET("/ws", require(auth.Viewer), wshandler)
+	router.GET("/events", require(auth.Viewer), eventsHandler)
+	router.GET("/metrics", require(auth.Viewer), metricsHandler)
+	router.GET("/probe", require(auth.Operator), probeHandler)
+
+	a := router.Group("/agent", require(auth.Viewer))
 	{
 		a.GET("/", func(c *gin.Context) {
 			c.JSON(200, plugins.AvailableAgents())
@@ -96,37 +78,75 @@ func Run(wg sync.WaitGroup) {
 
 	h := router.Group("/host")
 	{
-		h.DELETE("/:id", func(c *gin.Context) {
+		h.DELETE("/:id", require(auth.Admin), func(c *gin.Context) {
 			id := c.Param("id")
+			cascade := c.Query("cascade") == "true"
+
+			before, err := monitor.GetHost(id)
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(404, err)
+				return
+			}
 
-			err := monitor.DeleteHost(id)
+			monitors, err := monitor.GetMonitorsByHost(id)
 			if err != nil {
 				c.AbortWithError(500, err)
+				return
+			}
+
+			err = monitor.DeleteHost(id, cascade)
+			if err == monitor.ErrorHostInUse {
+				c.AbortWithError(409, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
 			} else {
+				for _, mon := range monitors {
+					record(c, "mondelete", mon.Id.Hex(), mon, nil)
+				}
+				record(c, "hostdelete", id, before, nil)
 				c.JSON(200, nil)
 			}
 		})
 
-		h.POST("/new", func(c *gin.Context) {
+		h.POST("/new", require(auth.Admin), func(c *gin.Context) {
 			var host monitor.Host
 			c.Bind(&host)
 			err := monitor.AddHost(&host)
-			if err != nil {
+			if _, ok := err.(*plugins.ValidationError); ok {
+				c.AbortWithError(400, err)
+			} else if err != nil {
 				c.AbortWithError(500, err)
 			} else {
+				record(c, "hostadd", host.Id.Hex(), nil, host)
 				c.JSON(200, host)
 			}
 		})
 
-		h.GET("/", func(c *gin.Context) {
-			c.JSON(200, monitor.GetAllHosts())
+		h.GET("/", require(auth.Viewer), func(c *gin.Context) {
+			options, err := listOptionsFromQuery(c)
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			hosts, next, err := monitor.ListHosts(options)
+			if err == monitor.ErrorInvalidCursor || err == monitor.ErrorInvalidSort {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				listResponse(c, hosts, next)
+			}
 		})
 	}
 
 	m := router.Group("/monitor")
 	{
 
-		m.GET("/:id", func(c *gin.Context) {
+		m.GET("/:id", require(auth.Viewer), func(c *gin.Context) {
 			id := c.Param("id")
 
 			mon, err := monitor.GetMonitor(id)
@@ -139,45 +159,102 @@ func Run(wg sync.WaitGroup) {
 			}
 		})
 
-		m.PUT("/:id", func(c *gin.Context) {
+		m.PUT("/:id", require(auth.Operator), func(c *gin.Context) {
 			var mon monitor.Monitor
 			c.Bind(&mon)
-			err := monitor.UpdateMonitor(&mon)
-			if err != nil {
+
+			before, err := monitor.GetMonitor(mon.Id.Hex())
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(404, err)
+				return
+			}
+
+			// Secrets are redacted in what clients get, and kept unless
+			// changed.
+			mon.Agent.RestoreSecrets(before.Agent)
+
+			if !allowJobs(c, before.Agent, mon.Agent) {
+				return
+			}
+
+			err = monitor.UpdateMonitor(&mon)
+			if _, ok := err.(*plugins.ValidationError); ok {
+				c.AbortWithError(400, err)
+			} else if err != nil {
 				c.AbortWithError(500, err)
 			} else {
+				record(c, "monchange", mon.Id.Hex(), before, mon)
 				c.JSON(200, mon)
 			}
 		})
 
-		m.DELETE("/:id", func(c *gin.Context) {
+		m.DELETE("/:id", require(auth.Operator), func(c *gin.Context) {
 			id := c.Param("id")
 
-			err := monitor.DeleteMonitor(id)
+			before, err := monitor.GetMonitor(id)
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(404, err)
+				return
+			}
+
+			err = monitor.DeleteMonitor(id)
 			if err != nil {
 				c.AbortWithError(500, err)
 			} else {
+				record(c, "mondelete", id, before, nil)
 				c.JSON(200, nil)
 			}
 		})
 
-		m.POST("/new", func(c *gin.Context) {
+		m.POST("/new", require(auth.Operator), func(c *gin.Context) {
 			var mon monitor.Monitor
 			c.Bind(&mon)
+
+			if !allowJobs(c, mon.Agent) {
+				return
+			}
+
 			err := monitor.AddMonitor(&mon)
-			if err != nil {
+			if _, ok := err.(*plugins.ValidationError); ok {
+				c.AbortWithError(400, err)
+			} else if err != nil {
 				c.AbortWithError(500, err)
 			} else {
+				record(c, "monadd", mon.Id.Hex(), nil, mon)
 				c.JSON(200, mon)
 			}
 		})
 
-		m.GET("/", func(c *gin.Context) {
-			c.JSON(200, monitor.GetAllMonitors())
+		m.POST("/:id/run", require(auth.Operator), commandHandler("run"))
+		m.POST("/:id/pause", require(auth.Operator), commandHandler("pause"))
+		m.POST("/:id/resume", require(auth.Operator), commandHandler("resume"))
+		m.POST("/:id/ack", require(auth.Operator), commandHandler("ack"))
+
+		m.GET("/", require(auth.Viewer), func(c *gin.Context) {
+			options, err := listOptionsFromQuery(c)
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			monitors, next, err := monitor.ListMonitors(options)
+			if err == monitor.ErrorInvalidCursor || err == monitor.ErrorInvalidSort {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				listResponse(c, monitors, next)
+			}
 		})
 	}
 
-	t := router.Group("/transport")
+	t := router.Group("/transport", require(auth.Viewer))
 	{
 		t.GET("/", func(c *gin.Context) {
 			c.JSON(200, plugins.AvailableTransports())
@@ -193,7 +270,10 @@ func Run(wg sync.WaitGroup) {
 		})
 	})
 
-	router.Run(":9901")
+	err := serve(config.Current.API, router)
+	if err != nil {
+		logger.Error("api", "Error serving API: %s", err.Error())
+	}
 
 	wg.Done()
 }
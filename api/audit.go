@@ -0,0 +1,95 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/audit"
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/monitor"
+)
+
+// record writes a change made through the API to the audit log.
+func record(c *gin.Context, typ string, objectId string, before interface{}, after interface{}) {
+	actor := "anonymous"
+	p := principal(c)
+	if p != nil {
+		actor = p.Name
+	}
+
+	err := audit.Record(audit.Entry{
+		Type:     typ,
+		ObjectId: objectId,
+		Actor:    actor,
+		SourceIP: c.ClientIP(),
+		Before:   redacted(before),
+		After:    redacted(after),
+	})
+	if err != nil {
+		logger.Error("api", "Error writing audit log: %s", err.Error())
+	}
+}
+
+// redacted returns v with secrets redacted. Audit entries are stored as
+// BSON, which keeps secrets unlike the JSON encoding.
+func redacted(v interface{}) interface{} {
+	switch v := v.(type) {
+	case monitor.Host:
+		return v.Redacted()
+	case monitor.Monitor:
+		return v.Redacted()
+	}
+
+	return v
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func auditRoutes(router *gin.Engine) {
+	router.GET("/audit", require(auth.Admin), func(c *gin.Context) {
+		var err error
+
+		filter := audit.Filter{
+			Type:     c.Query("type"),
+			ObjectId: c.Query("objectId"),
+			Actor:    c.Query("actor"),
+		}
+
+		filter.Since, err = parseTime(c.Query("since"))
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+
+		filter.Until, err = parseTime(c.Query("until"))
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+
+		limit := c.Query("limit")
+		if limit != "" {
+			filter.Limit, err = strconv.Atoi(limit)
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+		}
+
+		entries, err := audit.Find(filter)
+		if err != nil {
+			c.AbortWithError(500, err)
+		} else {
+			c.JSON(200, entries)
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	newUser struct {
+		Username string    `json:"username"`
+		Password string    `json:"password"`
+		Role     auth.Role `json:"role"`
+	}
+
+	newToken struct {
+		Name string    `json:"name"`
+		Role auth.Role `json:"role"`
+	}
+)
+
+const (
+	sessionCookie = "alerto-session"
+	principalKey  = "principal"
+)
+
+// authenticate finds the principal behind a request, either from an API
+// token in the Authorization header or from a session cookie.
+func authenticate(r *http.Request) (*auth.Principal, error) {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return auth.TokenPrincipal(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		return auth.SessionPrincipal(cookie.Value)
+	}
+
+	tlsConfig := config.Current.API.TLS
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && tlsConfig != nil && tlsConfig.ClientRole != "" {
+		return &auth.Principal{
+			Name: "certificate:" + r.TLS.VerifiedChains[0][0].Subject.CommonName,
+			Role: auth.Role(tlsConfig.ClientRole),
+			Kind: "certificate",
+		}, nil
+	}
+
+	return nil, auth.ErrorUnauthenticated
+}
+
+func logDenied(c *gin.Context, p *auth.Principal, err error) {
+	name := "anonymous"
+	if p != nil {
+		name = p.Name
+	}
+
+	logger.Error("api", "Denied %s %s for %s from %s: %s", c.Request.Method, c.Request.URL.Path, name, c.ClientIP(), err.Error())
+}
+
+func deny(c *gin.Context, code int, p *auth.Principal, err error) {
+	logDenied(c, p, err)
+	c.AbortWithError(code, err)
+}
+
+// require returns middleware that rejects requests from principals without
+// the required role.
+func require(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := authenticate(c.Request)
+		if err == auth.ErrorUnauthenticated {
+			deny(c, 401, nil, err)
+			return
+		} else if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+
+		if !p.Allows(role) {
+			deny(c, 403, p, auth.ErrorForbidden)
+			return
+		}
+
+		c.Set(principalKey, p)
+	}
+}
+
+// allowJobs aborts the request unless the principal is an admin or none
+// of jobs have privileged agents. Operators can't configure agents
+// running arbitrary commands.
+func allowJobs(c *gin.Context, jobs ...plugins.Job) bool {
+	p := principal(c)
+	if p != nil && p.Allows(auth.Admin) {
+		return true
+	}
+
+	for _, job := range jobs {
+		if job.Privileged() {
+			deny(c, 403, p, auth.ErrorForbidden)
+			return false
+		}
+	}
+
+	return true
+}
+
+// principal returns the principal stored by require.
+func principal(c *gin.Context) *auth.Principal {
+	p, found := c.Get(principalKey)
+	if !found {
+		return nil
+	}
+
+	return p.(*auth.Principal)
+}
+
+// setSessionCookie sets the session cookie. It's never sent with
+// requests from other sites, which protects against cross-site request
+// forgery.
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func login(c *gin.Context) {
+	var creds credentials
+	c.Bind(&creds)
+
+	user, err := auth.Authenticate(creds.Username, creds.Password)
+	if err == auth.ErrorInvalidCredentials {
+		deny(c, 401, nil, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	id, err := auth.NewSession(user)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	setSessionCookie(c, id, int(auth.SessionLifetime.Seconds()))
+
+	c.JSON(200, user.Principal())
+}
+
+func logout(c *gin.Context) {
+	cookie, err := c.Request.Cookie(sessionCookie)
+	if err == nil {
+		auth.EndSession(cookie.Value)
+	}
+
+	setSessionCookie(c, "", -1)
+
+	c.JSON(200, nil)
+}
+
+func authRoutes(router *gin.Engine) {
+	router.POST("/login", login)
+	router.POST("/logout", logout)
+
+	router.GET("/me", require(auth.Viewer), func(c *gin.Context) {
+		c.JSON(200, principal(c))
+	})
+
+	u := router.Group("/user", require(auth.Admin))
+	{
+		u.GET("/", func(c *gin.Context) {
+			c.JSON(200, auth.GetAllUsers())
+		})
+
+		u.POST("/new", func(c *gin.Context) {
+			var n newUser
+			c.Bind(&n)
+			user, err := auth.AddUser(n.Username, n.Password, n.Role)
+			if err == auth.ErrorInvalidRole {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, user)
+			}
+		})
+
+		u.PUT("/:id", func(c *gin.Context) {
+			var n newUser
+			c.Bind(&n)
+
+			user, err := auth.GetUser(c.Param("id"))
+			if err == auth.ErrorInvalidId {
+				c.AbortWithError(400, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(404, err)
+				return
+			}
+
+			if n.Role != "" {
+				user.Role = n.Role
+			}
+
+			if n.Password != "" {
+				err = user.SetPassword(n.Password)
+				if err != nil {
+					c.AbortWithError(500, err)
+					return
+				}
+			}
+
+			err = auth.UpdateUser(&user)
+			if err == auth.ErrorInvalidRole {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, user)
+			}
+		})
+
+		u.DELETE("/:id", func(c *gin.Context) {
+			err := auth.DeleteUser(c.Param("id"))
+			if err == auth.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, nil)
+			}
+		})
+	}
+
+	t := router.Group("/token", require(auth.Admin))
+	{
+		t.GET("/", func(c *gin.Context) {
+			c.JSON(200, auth.GetAllTokens())
+		})
+
+		t.POST("/new", func(c *gin.Context) {
+			var n newToken
+			c.Bind(&n)
+			token, secret, err := auth.AddToken(n.Name, n.Role)
+			if err == auth.ErrorInvalidRole {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, gin.H{"token": token, "secret": secret})
+			}
+		})
+
+		t.DELETE("/:id", func(c *gin.Context) {
+			err := auth.DeleteToken(c.Param("id"))
+			if err == auth.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, nil)
+			}
+		})
+	}
+}
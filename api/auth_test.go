@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/plugins"
+	"github.com/abrander/alerto/plugins/nagios"
+	"github.com/abrander/alerto/plugins/noop"
+)
+
+func TestAllowJobs(t *testing.T) {
+	privileged := plugins.Job{AgentId: "nagios", Agent: &nagios.Nagios{Command: "/bin/true"}}
+	harmless := plugins.Job{AgentId: "noop", Agent: &noop.Noop{}}
+
+	cases := []struct {
+		role    auth.Role
+		jobs    []plugins.Job
+		allowed bool
+	}{
+		{auth.Operator, []plugins.Job{harmless}, true},
+		{auth.Operator, []plugins.Job{privileged}, false},
+		{auth.Operator, []plugins.Job{harmless, privileged}, false},
+		{auth.Admin, []plugins.Job{privileged}, true},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/monitor/new", nil)
+		c.Set(principalKey, &auth.Principal{Name: "test", Role: tc.role})
+
+		allowed := allowJobs(c, tc.jobs...)
+		if allowed != tc.allowed {
+			t.Errorf("%s: Expected %v, got %v", tc.role, tc.allowed, allowed)
+		}
+
+		if !allowed && w.Code != 403 {
+			t.Errorf("%s: Expected 403, got %d", tc.role, w.Code)
+		}
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/login", nil)
+
+	setSessionCookie(c, "session", 3600)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %v", cookies)
+	}
+
+	cookie := cookies[0]
+	if cookie.Name != sessionCookie || cookie.Value != "session" || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Wrong cookie %s", cookie.String())
+	}
+}
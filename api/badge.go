@@ -0,0 +1,279 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/report"
+	"github.com/abrander/alerto/statuspage"
+)
+
+type (
+	badge struct {
+		Label        string
+		Message      string
+		Color        string
+		LabelWidth   int
+		MessageWidth int
+	}
+)
+
+const (
+	defaultBadgeDays = 30
+	maxBadgeDays     = 365
+)
+
+var (
+	badgeColors = map[string]string{
+		statuspage.Operational: "#4c1",
+		statuspage.Maintenance: "#007ec6",
+		statuspage.Unknown:     "#9f9f9f",
+		statuspage.Degraded:    "#dfb317",
+		statuspage.Outage:      "#e05d44",
+	}
+
+	badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{ .Width }}" height="20" role="img" aria-label="{{ .Label }}: {{ .Message }}">
+<title>{{ .Label }}: {{ .Message }}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{ .Width }}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="{{ .LabelWidth }}" height="20" fill="#555"/><rect x="{{ .LabelWidth }}" width="{{ .MessageWidth }}" height="20" fill="{{ .Color }}"/><rect width="{{ .Width }}" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{ .LabelX }}" y="15" fill="#010101" fill-opacity=".3">{{ .Label }}</text><text x="{{ .LabelX }}" y="14">{{ .Label }}</text>
+<text x="{{ .MessageX }}" y="15" fill="#010101" fill-opacity=".3">{{ .Message }}</text><text x="{{ .MessageX }}" y="14">{{ .Message }}</text>
+</g>
+</svg>
+`))
+)
+
+// textWidth is a rough estimate of the width of s in 11px Verdana.
+func textWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+func (b badge) Width() int {
+	return b.LabelWidth + b.MessageWidth
+}
+
+func (b badge) LabelX() int {
+	return b.LabelWidth / 2
+}
+
+func (b badge) MessageX() int {
+	return b.LabelWidth + b.MessageWidth/2
+}
+
+// badgeAllowed returns true if the request carries a valid token for
+// subject or comes from an authenticated viewer.
+func badgeAllowed(c *gin.Context, subject string) bool {
+	token := c.Query("token")
+	if token != "" && auth.ValidBadgeToken(subject, token) {
+		return true
+	}
+
+	p, err := authenticate(c.Request)
+	if err == nil && p.Allows(auth.Viewer) {
+		return true
+	}
+
+	logDenied(c, p, auth.ErrorForbidden)
+
+	return false
+}
+
+// renderBadge writes a badge for monitors. The ETag changes whenever one of
+// the monitors is checked, paused or enters maintenance.
+func renderBadge(c *gin.Context, label string, monitors []monitor.Monitor) {
+	show := c.DefaultQuery("show", "status")
+	if show != "status" && show != "uptime" {
+		c.AbortWithError(400, fmt.Errorf("cannot show '%s'", show))
+		return
+	}
+
+	days := defaultBadgeDays
+	if c.Query("days") != "" {
+		var err error
+		days, err = strconv.Atoi(c.Query("days"))
+		if err != nil || days < 1 || days > maxBadgeDays {
+			c.AbortWithError(400, fmt.Errorf("days must be between 1 and %d", maxBadgeDays))
+			return
+		}
+	}
+
+	if c.Query("label") != "" {
+		label = c.Query("label")
+	}
+
+	now := time.Now()
+
+	// Pausing a monitor or entering maintenance changes the badge without
+	// a new check.
+	var last time.Time
+	state := fnv.New64a()
+	for _, mon := range monitors {
+		if mon.LastCheck.After(last) {
+			last = mon.LastCheck
+		}
+
+		fmt.Fprintf(state, "%t%t", mon.Paused, monitor.InMaintenance(&mon, now))
+	}
+
+	etag := fmt.Sprintf(`"%x-%d-%x-%s-%d-%x"`, last.UnixNano(), len(monitors), state.Sum64(), show, days, label)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+
+	if c.Request.Header.Get("If-None-Match") == etag {
+		c.AbortWithStatus(304)
+		return
+	}
+
+	status := statuspage.Status(monitors, now)
+
+	b := badge{
+		Label:   label,
+		Message: status,
+		Color:   badgeColors[status],
+	}
+
+	if show == "uptime" {
+		uptime, err := report.Uptime(monitors, now.AddDate(0, 0, -days), now)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+
+		b.Message = "no data"
+		b.Color = badgeColors[statuspage.Unknown]
+
+		if uptime != nil {
+			b.Message = strconv.FormatFloat(*uptime, 'f', 2, 64) + "%"
+
+			switch {
+			case *uptime >= 99.9:
+				b.Color = badgeColors[statuspage.Operational]
+			case *uptime >= 99:
+				b.Color = badgeColors[statuspage.Degraded]
+			default:
+				b.Color = badgeColors[statuspage.Outage]
+			}
+		}
+	}
+
+	b.LabelWidth = textWidth(b.Label)
+	b.MessageWidth = textWidth(b.Message)
+
+	var buf bytes.Buffer
+	err := badgeTemplate.Execute(&buf, b)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	c.Data(200, "image/svg+xml", buf.Bytes())
+}
+
+func monitorBadgeHandler(c *gin.Context) {
+	id := strings.TrimSuffix(c.Param("file"), ".svg")
+
+	if !badgeAllowed(c, "monitor:"+id) {
+		c.AbortWithStatus(403)
+		return
+	}
+
+	mon, err := monitor.GetMonitor(id)
+	if err == monitor.ErrorInvalidId {
+		c.AbortWithError(400, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	label := mon.Name
+	if label == "" {
+		label = mon.Agent.AgentId
+	}
+
+	renderBadge(c, label, []monitor.Monitor{mon})
+}
+
+func labelBadgeHandler(c *gin.Context) {
+	selector, err := monitor.ParseSelector(strings.TrimSuffix(c.Param("file"), ".svg"))
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	if !badgeAllowed(c, "label:"+selector.String()) {
+		c.AbortWithStatus(403)
+		return
+	}
+
+	filter := monitor.Filter{Labels: selector.String()}
+	err = filter.Compile()
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	monitors, _, err := monitor.ListMonitors(monitor.ListOptions{Filter: filter})
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	renderBadge(c, selector.String(), monitors)
+}
+
+// badgeTokenHandler returns the paths of badges usable without logging in.
+func badgeTokenHandler(c *gin.Context) {
+	var subject, path string
+
+	if c.Query("monitorId") != "" {
+		_, err := monitor.GetMonitor(c.Query("monitorId"))
+		if err == monitor.ErrorInvalidId {
+			c.AbortWithError(400, err)
+			return
+		} else if err != nil {
+			c.AbortWithError(404, err)
+			return
+		}
+
+		subject = "monitor:" + c.Query("monitorId")
+		path = "/badge/monitor/" + c.Query("monitorId") + ".svg"
+	} else {
+		selector, err := monitor.ParseSelector(c.Query("labels"))
+		if err != nil || len(selector) == 0 {
+			c.AbortWithError(400, fmt.Errorf("monitorId or labels required"))
+			return
+		}
+
+		subject = "label:" + selector.String()
+		path = "/badge/label/" + url.PathEscape(selector.String()) + ".svg"
+	}
+
+	token := auth.BadgeToken(subject)
+
+	c.JSON(200, gin.H{
+		"token": token,
+		"path":  path + "?token=" + token,
+	})
+}
+
+func badgeRoutes(router *gin.Engine) {
+	b := router.Group("/badge")
+	{
+		b.GET("/monitor/:file", monitorBadgeHandler)
+		b.GET("/label/:file", labelBadgeHandler)
+		b.GET("/token", require(auth.Viewer), badgeTokenHandler)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/monitor"
+)
+
+type (
+	// command is an operator action on a single monitor. It can be issued
+	// through REST or the websocket.
+	command struct {
+		MonitorId string `json:"monitorId"`
+		Comment   string `json:"comment"`
+	}
+)
+
+var (
+	commandAuditTypes = map[string]string{
+		"run":    "monrun",
+		"pause":  "monpause",
+		"resume": "monresume",
+		"ack":    "monack",
+	}
+)
+
+// runCommand executes a command on behalf of the principal in c.
+func runCommand(c *gin.Context, typ string, cmd command) (monitor.Monitor, error) {
+	var mon monitor.Monitor
+
+	auditType, found := commandAuditTypes[typ]
+	if !found {
+		return mon, fmt.Errorf("unknown command '%s'", typ)
+	}
+
+	p := principal(c)
+	if !p.Allows(auth.Operator) {
+		logDenied(c, p, auth.ErrorForbidden)
+		return mon, auth.ErrorForbidden
+	}
+
+	before, err := monitor.GetMonitor(cmd.MonitorId)
+	if err != nil {
+		return mon, err
+	}
+
+	switch typ {
+	case "run":
+		mon, err = monitor.RunNow(cmd.MonitorId)
+	case "pause":
+		mon, err = monitor.SetPaused(cmd.MonitorId, true)
+	case "resume":
+		mon, err = monitor.SetPaused(cmd.MonitorId, false)
+	case "ack":
+		mon, err = monitor.Acknowledge(cmd.MonitorId, p.Name, cmd.Comment)
+	}
+
+	if err != nil {
+		return mon, err
+	}
+
+	record(c, auditType, cmd.MonitorId, before, mon)
+
+	return mon, nil
+}
+
+func commandHandler(typ string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var cmd command
+		if c.Request.ContentLength > 0 {
+			c.Bind(&cmd)
+		}
+		cmd.MonitorId = c.Param("id")
+
+		mon, err := runCommand(c, typ, cmd)
+		if err == monitor.ErrorInvalidId {
+			c.AbortWithError(400, err)
+		} else if err == auth.ErrorForbidden {
+			c.AbortWithError(403, err)
+		} else if err != nil {
+			c.AbortWithError(404, err)
+		} else {
+			c.JSON(200, mon)
+		}
+	}
+}
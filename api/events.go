@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/monitor"
+)
+
+const (
+	heartbeatPeriod = 15 * time.Second
+)
+
+// filterFromQuery builds a monitor filter from the query parameters hostId,
+// monitorId (repeatable) and labels.
+func filterFromQuery(c *gin.Context) (monitor.Filter, error) {
+	filter := monitor.Filter{
+		HostId:     c.Query("hostId"),
+		MonitorIds: c.Request.URL.Query()["monitorId"],
+		Labels:     c.Query("labels"),
+	}
+
+	return filter, filter.Compile()
+}
+
+// writeEvent writes a single Server-Sent Event. id is omitted if zero.
+func writeEvent(w io.Writer, id uint64, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if id > 0 {
+		_, err = fmt.Fprintf(w, "id: %d\n", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+
+	return err
+}
+
+// eventsHandler streams the same messages as the websocket as
+// text/event-stream. Clients resume with the Last-Event-ID header or the
+// since parameter. A typical script would do:
+//
+//	curl -N -H 'Authorization: Bearer <token>' 'http://localhost:9901/events?status=false&labels=env=prod'
+func eventsHandler(c *gin.Context) {
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	resume := c.Request.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = c.Query("since")
+	}
+
+	var since uint64
+	if resume != "" {
+		since, err = strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	changes, err := monitor.Subscribe(since, monitor.Disconnect)
+	defer monitor.Unsubscribe(changes)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	if err == monitor.ErrorSequenceTooOld {
+		err = writeEvent(w, 0, "resync", monitor.Sequence())
+		if err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	// Scripts will often want changes only, and can ask for ?status=false.
+	var tick <-chan time.Time
+	if c.Query("status") != "false" {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+
+	status := Status{
+		Started: StartTime,
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case t := <-tick:
+			status.Clock = t
+			status.Uptime = t.Sub(StartTime)
+			status.Seq = monitor.Sequence()
+			err = writeEvent(w, 0, "status", Message{Type: "status", Payload: status})
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		case msg, ok := <-changes.C:
+			if !ok {
+				return
+			}
+
+			if !filter.MatchChange(msg) {
+				continue
+			}
+
+			err = writeEvent(w, msg.Seq, msg.Type, msg)
+		}
+
+		if err != nil {
+			return
+		}
+
+		w.Flush()
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/monitor"
+)
+
+type (
+	note struct {
+		Text string `json:"text"`
+	}
+)
+
+func incidentRoutes(router *gin.Engine) {
+	i := router.Group("/incident")
+	{
+		i.GET("/", require(auth.Viewer), func(c *gin.Context) {
+			limit := 100
+			if c.Query("limit") != "" {
+				var err error
+				limit, err = strconv.Atoi(c.Query("limit"))
+				if err != nil {
+					c.AbortWithError(400, err)
+					return
+				}
+			}
+
+			incidents, err := monitor.GetIncidents(c.Query("open") == "true", c.Query("monitorId"), limit)
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, incidents)
+			}
+		})
+
+		i.GET("/:id", require(auth.Viewer), func(c *gin.Context) {
+			incident, err := monitor.GetIncident(c.Param("id"))
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err == mgo.ErrNotFound {
+				c.AbortWithError(404, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, incident)
+			}
+		})
+
+		i.POST("/:id/note", require(auth.Operator), func(c *gin.Context) {
+			var n note
+			c.Bind(&n)
+
+			incident, err := monitor.AddIncidentNote(c.Param("id"), principal(c).Name, n.Text)
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err == mgo.ErrNotFound {
+				c.AbortWithError(404, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				record(c, "incidentnote", incident.Id.Hex(), nil, n)
+				c.JSON(200, incident)
+			}
+		})
+	}
+}
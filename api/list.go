@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/monitor"
+)
+
+const (
+	maxLimit = 1000
+)
+
+// listOptionsFromQuery reads paging, sorting and search options in
+// addition to the filter from filterFromQuery.
+func listOptionsFromQuery(c *gin.Context) (monitor.ListOptions, error) {
+	var err error
+
+	options := monitor.ListOptions{
+		Search: c.Query("q"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	options.Filter, err = filterFromQuery(c)
+	if err != nil {
+		return options, err
+	}
+
+	limit := c.Query("limit")
+	if limit != "" {
+		options.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return options, err
+		}
+
+		// Zero means no limit to monitor.List, which would bypass the cap.
+		if options.Limit < 1 {
+			return options, fmt.Errorf("limit must be positive")
+		}
+
+		if options.Limit > maxLimit {
+			options.Limit = maxLimit
+		}
+	}
+
+	return options, nil
+}
+
+// selectFields reduces the JSON representation of v to the comma separated
+// list of dotted paths in fields. Paths prefixed by "-" are removed
+// instead. An empty list returns v unchanged.
+func selectFields(v interface{}, fields string) (interface{}, error) {
+	if fields == "" {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var include, exclude [][]string
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "-") {
+			exclude = append(exclude, strings.Split(field[1:], "."))
+		} else if field != "" {
+			include = append(include, strings.Split(field, "."))
+		}
+	}
+
+	apply := func(item interface{}) interface{} {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return item
+		}
+
+		if len(include) > 0 {
+			selected := make(map[string]interface{})
+			for _, path := range include {
+				copyPath(m, selected, path)
+			}
+			m = selected
+		}
+
+		for _, path := range exclude {
+			removePath(m, path)
+		}
+
+		return m
+	}
+
+	list, ok := doc.([]interface{})
+	if !ok {
+		return apply(doc), nil
+	}
+
+	for i, item := range list {
+		list[i] = apply(item)
+	}
+
+	return list, nil
+}
+
+func copyPath(from map[string]interface{}, to map[string]interface{}, path []string) {
+	value, found := from[path[0]]
+	if !found {
+		return
+	}
+
+	if len(path) == 1 {
+		to[path[0]] = value
+		return
+	}
+
+	sub, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	target, ok := to[path[0]].(map[string]interface{})
+	if !ok {
+		target = make(map[string]interface{})
+		to[path[0]] = target
+	}
+
+	copyPath(sub, target, path[1:])
+}
+
+func removePath(m map[string]interface{}, path []string) {
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+
+	sub, ok := m[path[0]].(map[string]interface{})
+	if ok {
+		removePath(sub, path[1:])
+	}
+}
+
+// listResponse writes items as a JSON array. The cursor for the next page
+// is given in the X-Next-Cursor header and as a Link header, leaving the
+// body compatible with clients not knowing about paging.
+func listResponse(c *gin.Context, items interface{}, next string) {
+	result, err := selectFields(items, c.Query("fields"))
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	if next != "" {
+		query := c.Request.URL.Query()
+		query.Set("cursor", next)
+
+		u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+
+		c.Header("X-Next-Cursor", next)
+		c.Header("Link", "<"+u.String()+">; rel=\"next\"")
+	}
+
+	c.JSON(200, result)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListLimit(t *testing.T) {
+	cases := []struct {
+		query    string
+		limit    int
+		rejected bool
+	}{
+		{"", 0, false},
+		{"limit=10", 10, false},
+		{"limit=1000000", maxLimit, false},
+		{"limit=0", 0, true},
+		{"limit=-1", 0, true},
+		{"limit=ten", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest("GET", "/monitor/?"+c.query, nil)
+
+			options, err := listOptionsFromQuery(ctx)
+			if (err != nil) != c.rejected {
+				t.Fatalf("Expected rejected %t, got %v", c.rejected, err)
+			}
+
+			if err == nil && options.Limit != c.limit {
+				t.Errorf("Expected limit %d, got %d", c.limit, options.Limit)
+			}
+		})
+	}
+}
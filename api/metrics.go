@@ -0,0 +1,60 @@
+package api
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/metrics"
+)
+
+var (
+	requestsTotal       = metrics.NewCounter("alerto_api_requests_total", "Number of API requests.", "method", "path", "code")
+	requestSecondsTotal = metrics.NewCounter("alerto_api_request_seconds_total", "Total time spent serving API requests.", "method", "path")
+)
+
+func init() {
+	metrics.Register(metrics.CollectorFunc(func() []metrics.Family {
+		return []metrics.Family{
+			{
+				Name:    "alerto_start_time_seconds",
+				Help:    "Start time of alerto since unix epoch.",
+				Type:    metrics.Gauge,
+				Samples: []metrics.Sample{{Value: float64(StartTime.UnixNano()) / 1e9}},
+			},
+			{
+				Name:    "go_goroutines",
+				Help:    "Number of goroutines that currently exist.",
+				Type:    metrics.Gauge,
+				Samples: []metrics.Sample{{Value: float64(runtime.NumGoroutine())}},
+			},
+		}
+	}))
+}
+
+// instrument is middleware counting requests. Requests are labelled by
+// route, like "/monitor/:id", to keep the number of label values down.
+func instrument(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	path := c.FullPath()
+	if path == "" {
+		// Don't let random URLs create new series.
+		path = "unmatched"
+	}
+	method := c.Request.Method
+
+	requestsTotal.Inc(method, path, strconv.Itoa(c.Writer.Status()))
+	requestSecondsTotal.Add(time.Since(start).Seconds(), method, path)
+}
+
+func metricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(200)
+
+	metrics.Write(c.Writer, metrics.Gather())
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/metrics"
+)
+
+func TestInstrument(t *testing.T) {
+	router := gin.New()
+	router.Use(instrument)
+	router.GET("/instrumented/:id", func(c *gin.Context) {
+		c.Status(204)
+	})
+	router.GET("/instrumented/:id/missing", func(c *gin.Context) {
+		c.AbortWithStatus(404)
+	})
+
+	for _, path := range []string{"/instrumented/one", "/instrumented/two", "/instrumented/one/missing", "/random"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	var out strings.Builder
+	metrics.Write(&out, requestsTotal.Collect())
+
+	for _, line := range []string{
+		`alerto_api_requests_total{code="204",method="GET",path="/instrumented/:id"} 2`,
+		`alerto_api_requests_total{code="404",method="GET",path="/instrumented/:id/missing"} 1`,
+		`alerto_api_requests_total{code="404",method="GET",path="unmatched"} 1`,
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %s in\n%s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package api
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/audit"
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+	"github.com/abrander/alerto/report"
+	"github.com/abrander/alerto/statuspage"
+)
+
+type (
+	// operation documents a route. Request and Response are values of the
+	// types sent and returned as JSON. Public routes need no
+	// authentication.
+	operation struct {
+		Summary     string
+		Public      bool
+		Role        auth.Role
+		Query       []string
+		Request     interface{}
+		Response    interface{}
+		ContentType string
+	}
+
+	// schemaGenerator builds schemas for Go types. Named structs are
+	// added to components and referenced.
+	schemaGenerator struct {
+		components plugins.Schema
+		names      map[reflect.Type]string
+	}
+)
+
+var (
+	listQuery   = []string{"q", "sort", "cursor", "limit", "fields"}
+	filterQuery = []string{"hostId", "monitorId", "labels"}
+
+	operations = map[string]operation{
+		"GET /ws":                  {Summary: "Websocket streaming changes", Role: auth.Viewer, Query: []string{"since"}},
+		"GET /events":              {Summary: "Server-sent events streaming changes", Role: auth.Viewer, Query: append([]string{"since", "status"}, filterQuery...), ContentType: "text/event-stream"},
+		"GET /metrics":             {Summary: "Prometheus metrics", Role: auth.Viewer, ContentType: "text/plain"},
+		"GET /probe":               {Summary: "Run an agent once. Agents calculating rates are sampled twice, a second apart. Other query parameters are passed to the agent. Privileged agents require admin", Role: auth.Operator, Query: queryNames(probeParameters), ContentType: "text/plain"},
+		"GET /openapi.json":        {Summary: "This document", Role: auth.Viewer, Response: plugins.Schema{}},
+		"GET /agent/":              {Summary: "List agents", Role: auth.Viewer, Response: map[string]plugins.Description{}},
+		"GET /transport/":          {Summary: "List transports", Role: auth.Viewer, Response: map[string]plugins.Description{}},
+		"GET /schema/":             {Summary: "JSON Schemas for the arguments of all plugins", Role: auth.Viewer, Response: map[string]plugins.Schema{}},
+		"GET /schema/:id":          {Summary: "JSON Schema for the arguments of a plugin", Role: auth.Viewer, Response: plugins.Schema{}},
+		"GET /host/":               {Summary: "List hosts", Role: auth.Viewer, Query: listQuery, Response: []monitor.Host{}},
+		"POST /host/new":           {Summary: "Add a host", Role: auth.Admin, Request: monitor.Host{}, Response: monitor.Host{}},
+		"DELETE /host/:id":         {Summary: "Delete a host", Role: auth.Admin, Query: []string{"cascade"}},
+		"GET /monitor/":            {Summary: "List monitors", Role: auth.Viewer, Query: append(listQuery, filterQuery...), Response: []monitor.Monitor{}},
+		"GET /monitor/:id":         {Summary: "Get a monitor", Role: auth.Viewer, Response: monitor.Monitor{}},
+		"PUT /monitor/:id":         {Summary: "Update a monitor. Privileged agents require admin", Role: auth.Operator, Request: monitor.Monitor{}, Response: monitor.Monitor{}},
+		"DELETE /monitor/:id":      {Summary: "Delete a monitor", Role: auth.Operator},
+		"POST /monitor/new":        {Summary: "Add a monitor. Privileged agents require admin", Role: auth.Operator, Request: monitor.Monitor{}, Response: monitor.Monitor{}},
+		"POST /monitor/:id/run":    {Summary: "Check a monitor now", Role: auth.Operator, Response: monitor.Monitor{}},
+		"POST /monitor/:id/pause":  {Summary: "Pause a monitor", Role: auth.Operator, Request: command{}, Response: monitor.Monitor{}},
+		"POST /monitor/:id/resume": {Summary: "Resume a monitor", Role: auth.Operator, Request: command{}, Response: monitor.Monitor{}},
+		"POST /monitor/:id/ack":    {Summary: "Acknowledge a failing monitor", Role: auth.Operator, Request: command{}, Response: monitor.Monitor{}},
+		"GET /monitor/:id/history": {Summary: "Recorded results", Role: auth.Viewer, Query: []string{"from", "to", "limit"}, Response: []monitor.ResultRecord{}},
+		"GET /incident/":           {Summary: "List incidents", Role: auth.Viewer, Query: []string{"open", "monitorId", "limit"}, Response: []monitor.Incident{}},
+		"GET /incident/:id":        {Summary: "Get an incident", Role: auth.Viewer, Response: monitor.Incident{}},
+		"POST /incident/:id/note":  {Summary: "Add a note to an incident", Role: auth.Operator, Request: note{}, Response: monitor.Incident{}},
+		"GET /maintenance/":        {Summary: "List maintenance windows", Role: auth.Viewer, Query: []string{"from", "to"}, Response: []monitor.Maintenance{}},
+		"POST /maintenance/new":    {Summary: "Add a maintenance window", Role: auth.Operator, Request: monitor.Maintenance{}, Response: monitor.Maintenance{}},
+		"DELETE /maintenance/:id":  {Summary: "Delete a maintenance window", Role: auth.Operator},
+		"GET /report":              {Summary: "Availability report", Role: auth.Viewer, Query: append([]string{"from", "to", "groupBy", "format"}, filterQuery...), Response: report.Report{}},
+		"GET /status/:name":        {Summary: "Public status page", Public: true, Query: []string{"format"}, Response: statuspage.Page{}, ContentType: "text/html"},
+		"GET /announcement/":       {Summary: "List announcements", Role: auth.Viewer, Query: []string{"page"}, Response: []statuspage.Announcement{}},
+		"POST /announcement/new":   {Summary: "Post an announcement", Role: auth.Operator, Request: statuspage.Announcement{}, Response: statuspage.Announcement{}},
+		"DELETE /announcement/:id": {Summary: "Delete an announcement", Role: auth.Operator},
+		"GET /badge/monitor/:file": {Summary: "Badge for a monitor", Public: true, Query: []string{"token", "show", "days", "label"}, ContentType: "image/svg+xml"},
+		"GET /badge/label/:file":   {Summary: "Badge for monitors matching a label selector", Public: true, Query: []string{"token", "show", "days", "label"}, ContentType: "image/svg+xml"},
+		"GET /badge/token":         {Summary: "Token for anonymous access to a badge", Role: auth.Viewer, Query: []string{"monitorId", "labels"}, Response: map[string]string{}},
+		"POST /script/test":        {Summary: "Run a script against canned data", Role: auth.Operator, Request: scriptTest{}, Response: plugins.Result{}},
+		"GET /audit":               {Summary: "Audit log", Role: auth.Admin, Query: []string{"type", "objectId", "actor", "since", "until", "limit"}, Response: []audit.Entry{}},
+		"POST /login":              {Summary: "Log in", Public: true, Request: credentials{}, Response: auth.Principal{}},
+		"POST /logout":             {Summary: "Log out", Public: true},
+		"GET /me":                  {Summary: "The authenticated principal", Role: auth.Viewer, Response: auth.Principal{}},
+		"GET /user/":               {Summary: "List users", Role: auth.Admin, Response: []auth.User{}},
+		"POST /user/new":           {Summary: "Add a user", Role: auth.Admin, Request: newUser{}, Response: auth.User{}},
+		"PUT /user/:id":            {Summary: "Update a user", Role: auth.Admin, Request: newUser{}, Response: auth.User{}},
+		"DELETE /user/:id":         {Summary: "Delete a user", Role: auth.Admin},
+		"GET /token/":              {Summary: "List API tokens", Role: auth.Admin, Response: []auth.Token{}},
+		"POST /token/new":          {Summary: "Add an API token", Role: auth.Admin, Request: newToken{}, Response: map[string]interface{}{}},
+		"DELETE /token/:id":        {Summary: "Delete an API token", Role: auth.Admin},
+	}
+
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	objectIdType = reflect.TypeOf(bson.ObjectId(""))
+	hostType     = reflect.TypeOf(monitor.Host{})
+	jobType      = reflect.TypeOf(plugins.Job{})
+)
+
+// queryNames returns the sorted names of a set of query parameters.
+func queryNames(parameters map[string]bool) []string {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func ref(name string) plugins.Schema {
+	return plugins.Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// fieldName returns the JSON name of a field as encoding/json would.
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+
+	return name
+}
+
+func (g *schemaGenerator) properties(t reflect.Type, properties plugins.Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			g.properties(f.Type, properties)
+			continue
+		}
+
+		name := fieldName(f)
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		properties[name] = g.schema(f.Type)
+	}
+}
+
+// named adds a schema to components under a unique name based on the
+// type name.
+func (g *schemaGenerator) named(t reflect.Type, build func() plugins.Schema) plugins.Schema {
+	name, found := g.names[t]
+	if found {
+		return ref(name)
+	}
+
+	name = t.Name()
+	if _, taken := g.components[name]; taken {
+		name = strings.Replace(t.String(), ".", "", -1)
+	}
+
+	g.names[t] = name
+	g.components[name] = plugins.Schema{}
+	g.components[name] = build()
+
+	return ref(name)
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) plugins.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return plugins.Schema{"type": "string", "format": "date-time"}
+	case durationType:
+		return plugins.Schema{"type": "integer", "format": "int64", "description": "Nanoseconds"}
+	case objectIdType:
+		return plugins.Schema{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	case hostType:
+		return g.named(t, func() plugins.Schema {
+			return g.discriminated("Host", "transportId", "transport", plugins.AvailableTransports(), g.hostBase())
+		})
+	case jobType:
+		return g.named(t, func() plugins.Schema {
+			return g.discriminated("Job", "agentId", "arguments", plugins.AvailableAgents(), plugins.Schema{
+				"timeout": g.schema(durationType),
+			})
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return plugins.Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return plugins.Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return plugins.Schema{"type": "number"}
+	case reflect.String:
+		return plugins.Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return plugins.Schema{"type": "string", "format": "byte"}
+		}
+
+		return plugins.Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return plugins.Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		build := func() plugins.Schema {
+			properties := plugins.Schema{}
+			g.properties(t, properties)
+
+			return plugins.Schema{"type": "object", "properties": properties}
+		}
+
+		if t.Name() == "" {
+			return build()
+		}
+
+		return g.named(t, build)
+	}
+
+	return plugins.Schema{}
+}
+
+func (g *schemaGenerator) hostBase() plugins.Schema {
+	return plugins.Schema{
+		"id":   g.schema(objectIdType),
+		"name": g.schema(reflect.TypeOf("")),
+	}
+}
+
+// discriminated returns a schema choosing between one variant per plugin,
+// based on the plugin id in the property key. The plugin arguments are
+// found in the property value.
+func (g *schemaGenerator) discriminated(name string, key string, value string, descriptions map[string]plugins.Description, common plugins.Schema) plugins.Schema {
+	ids := []string{}
+	for id := range descriptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	oneOf := []plugins.Schema{}
+	mapping := map[string]string{}
+
+	for _, id := range ids {
+		properties := plugins.Schema{
+			key:   plugins.Schema{"type": "string", "enum": []string{id}},
+			value: descriptions[id].Schema(),
+		}
+
+		for k, v := range common {
+			properties[k] = v
+		}
+
+		variant := name + "." + id
+		g.components[variant] = plugins.Schema{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{key, value},
+		}
+
+		oneOf = append(oneOf, ref(variant))
+		mapping[id] = "#/components/schemas/" + variant
+	}
+
+	return plugins.Schema{
+		"oneOf": oneOf,
+		"discriminator": plugins.Schema{
+			"propertyName": key,
+			"mapping":      mapping,
+		},
+	}
+}
+
+// openapiPath converts a gin path to an OpenAPI path and returns the
+// names of the path parameters.
+func openapiPath(path string) (string, []string) {
+	parts := strings.Split(path, "/")
+	params := []string{}
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+// operationId returns an id like "getMonitorIdHistory".
+func operationId(method string, path string) string {
+	id := strings.ToLower(method)
+
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '.' || r == '*'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return id
+}
+
+func (g *schemaGenerator) operation(method string, path string, op operation) plugins.Schema {
+	_, pathParams := openapiPath(path)
+
+	o := plugins.Schema{
+		"operationId": operationId(method, path),
+	}
+
+	if op.Summary != "" {
+		o["summary"] = op.Summary
+	}
+
+	parameters := []plugins.Schema{}
+	for _, name := range pathParams {
+		parameters = append(parameters, plugins.Schema{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   plugins.Schema{"type": "string"},
+		})
+	}
+
+	for _, name := range op.Query {
+		parameters = append(parameters, plugins.Schema{
+			"name":   name,
+			"in":     "query",
+			"schema": plugins.Schema{"type": "string"},
+		})
+	}
+
+	if len(parameters) > 0 {
+		o["parameters"] = parameters
+	}
+
+	if op.Request != nil {
+		o["requestBody"] = plugins.Schema{
+			"required": true,
+			"content": plugins.Schema{
+				"application/json": plugins.Schema{"schema": g.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	ok := plugins.Schema{"description": "OK"}
+	content := plugins.Schema{}
+
+	if op.Response != nil {
+		content["application/json"] = plugins.Schema{"schema": g.schema(reflect.TypeOf(op.Response))}
+	}
+
+	if op.ContentType != "" {
+		content[op.ContentType] = plugins.Schema{}
+	}
+
+	if len(content) > 0 {
+		ok["content"] = content
+	}
+
+	o["responses"] = plugins.Schema{
+		"200":     ok,
+		"default": plugins.Schema{"description": "Error"},
+	}
+
+	if op.Public {
+		o["security"] = []plugins.Schema{}
+	}
+
+	if op.Role != "" {
+		o["x-role"] = op.Role
+	}
+
+	return o
+}
+
+// openapi generates an OpenAPI document for the routes of router.
+func openapi(router *gin.Engine) plugins.Schema {
+	g := &schemaGenerator{
+		components: plugins.Schema{},
+		names:      make(map[reflect.Type]string),
+	}
+
+	paths := plugins.Schema{}
+
+	for _, route := range router.Routes() {
+		// Routes not documented in operations are still listed.
+		op := operations[route.Method+" "+route.Path]
+
+		p, _ := openapiPath(route.Path)
+
+		item, found := paths[p].(plugins.Schema)
+		if !found {
+			item = plugins.Schema{}
+			paths[p] = item
+		}
+
+		item[strings.ToLower(route.Method)] = g.operation(route.Method, route.Path, op)
+	}
+
+	return plugins.Schema{
+		"openapi": "3.0.3",
+		"info": plugins.Schema{
+			"title":   "Alerto",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": plugins.Schema{
+			"schemas": g.components,
+			"securitySchemes": plugins.Schema{
+				"token": plugins.Schema{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+				"session": plugins.Schema{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": sessionCookie,
+				},
+			},
+		},
+		"security": []plugins.Schema{
+			{"token": []string{}},
+			{"session": []string{}},
+		},
+	}
+}
+
+func openapiHandler(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, openapi(router))
+	}
+}
+
+// schemaDocument returns a standalone JSON Schema for the arguments of a
+// plugin.
+func schemaDocument(d plugins.Description) plugins.Schema {
+	s := d.Schema()
+	s["$schema"] = plugins.SchemaDialect
+
+	return s
+}
+
+func schemaRoutes(router *gin.Engine) {
+	router.GET("/openapi.json", require(auth.Viewer), openapiHandler(router))
+
+	s := router.Group("/schema", require(auth.Viewer))
+	{
+		s.GET("/", func(c *gin.Context) {
+			schemas := make(map[string]plugins.Schema)
+			for id, d := range plugins.AvailablePlugins() {
+				schemas[id] = schemaDocument(d)
+			}
+
+			c.JSON(200, schemas)
+		})
+
+		s.GET("/:id", func(c *gin.Context) {
+			d, found := plugins.AvailablePlugins()[c.Param("id")]
+			if !found {
+				c.AbortWithStatus(404)
+				return
+			}
+
+			c.JSON(200, schemaDocument(d))
+		})
+	}
+}
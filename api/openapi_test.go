@@ -0,0 +1,22 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func TestProbeQuery(t *testing.T) {
+	g := &schemaGenerator{components: plugins.Schema{}, names: map[reflect.Type]string{}}
+	op := g.operation("GET", "/probe", operations["GET /probe"])
+
+	documented := map[string]bool{}
+	for _, p := range op["parameters"].([]plugins.Schema) {
+		documented[p["name"].(string)] = true
+	}
+
+	if !reflect.DeepEqual(documented, probeParameters) {
+		t.Errorf("Documented %v, probe reads %v", documented, probeParameters)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/metrics"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+)
+
+const (
+	localhostId       = "000000000000000000000000"
+	defaultProbeTime  = 10 * time.Second
+	probeTimeoutSlack = 500 * time.Millisecond
+)
+
+var (
+	// Query parameters used by the probe itself. Everything else is
+	// passed to the agent.
+	probeParameters = map[string]bool{
+		"agent":   true,
+		"host":    true,
+		"timeout": true,
+		"format":  true,
+	}
+)
+
+// probeTimeout returns the timeout requested by the timeout parameter or
+// by Prometheus in X-Prometheus-Scrape-Timeout-Seconds.
+func probeTimeout(c *gin.Context) (time.Duration, error) {
+	if c.Query("timeout") != "" {
+		return time.ParseDuration(c.Query("timeout"))
+	}
+
+	header := c.Request.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header != "" {
+		seconds, err := strconv.ParseFloat(header, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		timeout := time.Duration(seconds*float64(time.Second)) - probeTimeoutSlack
+		if timeout > 0 {
+			return timeout, nil
+		}
+	}
+
+	return defaultProbeTime, nil
+}
+
+func probeFamilies(agentId string, result plugins.Result) []metrics.Family {
+	success := 0.0
+	if result.Status == plugins.Ok {
+		success = 1.0
+	}
+
+	families := []metrics.Family{
+		{
+			Name:    "probe_success",
+			Help:    "1 if the probe succeeded.",
+			Type:    metrics.Gauge,
+			Samples: []metrics.Sample{{Value: success}},
+		},
+		{
+			Name:    "probe_status",
+			Help:    "Status returned by the agent. 0 is ok.",
+			Type:    metrics.Gauge,
+			Samples: []metrics.Sample{{Value: float64(result.Status)}},
+		},
+		{
+			Name:    "probe_duration_seconds",
+			Help:    "Time taken by the probe.",
+			Type:    metrics.Gauge,
+			Samples: []metrics.Sample{{Value: result.Duration.Seconds()}},
+		},
+	}
+
+	measurement := metrics.Family{
+		Name: "probe_measurement",
+		Help: "Measurements returned by the agent.",
+		Type: metrics.Gauge,
+	}
+
+	if result.Measurements != nil {
+		for key, value := range *result.Measurements {
+			labels := metrics.Labels{"agent_id": agentId, "key": key}
+			if value.Unit != "" {
+				labels["unit"] = string(value.Unit)
+			}
+
+			measurement.Samples = append(measurement.Samples, metrics.Sample{
+				Labels: labels,
+				Value:  value.Value,
+			})
+		}
+	}
+
+	return append(families, measurement)
+}
+
+// probeHandler runs a single check through a host's transport and returns
+// the result, in the style of the Prometheus blackbox exporter.
+func probeHandler(c *gin.Context) {
+	agentId := c.Query("agent")
+
+	hostId := c.Query("host")
+	if hostId == "" {
+		hostId = localhostId
+	}
+
+	timeout, err := probeTimeout(c)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	arguments := make(map[string][]string)
+	for key, values := range c.Request.URL.Query() {
+		if !probeParameters[key] {
+			arguments[key] = values
+		}
+	}
+
+	job, err := plugins.NewJobFromValues(agentId, arguments)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+	job.Timeout = timeout
+
+	if !allowJobs(c, *job) {
+		return
+	}
+
+	host, err := monitor.GetHost(hostId)
+	if err == monitor.ErrorInvalidId {
+		c.AbortWithError(400, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	result := job.Run(host.Transport)
+
+	if c.Query("format") == "json" {
+		c.JSON(200, result)
+		return
+	}
+
+	var buf bytes.Buffer
+	metrics.Write(&buf, probeFamilies(agentId, result))
+
+	c.Data(200, "text/plain; version=0.0.4", buf.Bytes())
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/report"
+)
+
+const (
+	defaultReportPeriod = 30 * 24 * time.Hour
+)
+
+func reportHandler(c *gin.Context) {
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	options := report.Options{
+		Filter:  filter,
+		GroupBy: c.Query("groupBy"),
+	}
+
+	options.From, err = parseTime(c.Query("from"))
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	options.To, err = parseTime(c.Query("to"))
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	if options.To.IsZero() {
+		options.To = time.Now()
+	}
+
+	if options.From.IsZero() {
+		options.From = options.To.Add(-defaultReportPeriod)
+	}
+
+	r, err := report.Generate(options)
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		var buf bytes.Buffer
+		err = r.WriteCSV(&buf)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+
+		c.Header("Content-Disposition", "attachment; filename=alerto-report.csv")
+		c.Data(200, "text/csv", buf.Bytes())
+		return
+	}
+
+	c.JSON(200, r)
+}
+
+func historyHandler(c *gin.Context) {
+	from, err := parseTime(c.Query("from"))
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	to, err := parseTime(c.Query("to"))
+	if err != nil {
+		c.AbortWithError(400, err)
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	limit := 100
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	results, err := monitor.GetResults(c.Param("id"), from, to, limit)
+	if err == monitor.ErrorInvalidId {
+		c.AbortWithError(400, err)
+	} else if err != nil {
+		c.AbortWithError(500, err)
+	} else {
+		c.JSON(200, results)
+	}
+}
+
+func reportRoutes(router *gin.Engine) {
+	router.GET("/report", require(auth.Viewer), reportHandler)
+	router.GET("/monitor/:id/history", require(auth.Viewer), historyHandler)
+
+	m := router.Group("/maintenance")
+	{
+		m.GET("/", require(auth.Viewer), func(c *gin.Context) {
+			from, err := parseTime(c.Query("from"))
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			to, err := parseTime(c.Query("to"))
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			if from.IsZero() {
+				from = time.Now()
+			}
+
+			if to.IsZero() {
+				to = from.AddDate(1, 0, 0)
+			}
+
+			c.JSON(200, monitor.GetMaintenance(from, to))
+		})
+
+		m.POST("/new", require(auth.Operator), func(c *gin.Context) {
+			var maintenance monitor.Maintenance
+			c.Bind(&maintenance)
+
+			err := monitor.AddMaintenance(&maintenance)
+			if err != nil {
+				c.AbortWithError(400, err)
+			} else {
+				record(c, "maintenanceadd", maintenance.Id.Hex(), nil, maintenance)
+				c.JSON(200, maintenance)
+			}
+		})
+
+		m.DELETE("/:id", require(auth.Operator), func(c *gin.Context) {
+			id := c.Param("id")
+
+			err := monitor.DeleteMaintenance(id)
+			if err == monitor.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				record(c, "maintenancedelete", id, nil, nil)
+				c.JSON(200, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/plugins/script"
+)
+
+type (
+	scriptTest struct {
+		Source  string         `json:"source"`
+		Timeout time.Duration  `json:"timeout"`
+		Harness script.Harness `json:"harness"`
+	}
+)
+
+func scriptRoutes(router *gin.Engine) {
+	router.POST("/script/test", require(auth.Operator), func(c *gin.Context) {
+		var test scriptTest
+		c.Bind(&test)
+		c.JSON(200, test.Harness.Run(test.Source, test.Timeout))
+	})
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/statuspage"
+)
+
+var (
+	statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+		"percent": func(f *float64) string {
+			if f == nil {
+				return "no data"
+			}
+
+			return fmt.Sprintf("%.2f%%", *f)
+		},
+		"color": func(f *float64) string {
+			switch {
+			case f == nil:
+				return "#ccc"
+			case *f >= 99.9:
+				return "#5cb85c"
+			case *f >= 99:
+				return "#f0ad4e"
+			}
+
+			return "#d9534f"
+		},
+	}).Parse(statusHTML))
+)
+
+const statusHTML = `<!doctype html>
+<html>
+  <head>
+    <title>{{ .Title }}</title>
+    <link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.6/css/bootstrap.min.css">
+    <style>
+.bars { display: flex; height: 30px; }
+.bars span { flex: 1; margin-right: 1px; }
+.status-operational { color: #5cb85c; }
+.status-maintenance { color: #5bc0de; }
+.status-unknown { color: #777; }
+.status-degraded { color: #f0ad4e; }
+.status-outage { color: #d9534f; }
+    </style>
+  </head>
+  <body>
+   <div class="container">
+    <h1>{{ .Title }}</h1>
+    <h3 class="status-{{ .Status }}">{{ .Status }}</h3>
+    {{ range .Announcements }}
+    <div class="alert alert-{{ if eq .Severity "critical" }}danger{{ else if eq .Severity "warning" }}warning{{ else }}info{{ end }}">
+     <b>{{ .Title }}</b> <small>{{ .Time.Format "2006-01-02 15:04 MST" }}</small>
+     <p>{{ .Text }}</p>
+    </div>
+    {{ end }}
+    {{ range .Components }}
+    <div class="panel panel-default">
+     <div class="panel-heading">{{ .Name }} <span class="pull-right status-{{ .Status }}">{{ .Status }}</span></div>
+     <div class="panel-body">
+      <div class="bars">
+       {{ range .Days }}<span style="background: {{ color .Availability }};" title="{{ .Date.Format "2006-01-02" }}: {{ percent .Availability }}"></span>{{ end }}
+      </div>
+      <small>{{ percent .Uptime }} uptime over the last 90 days</small>
+     </div>
+    </div>
+    {{ end }}
+    <small>Updated {{ .Updated.Format "2006-01-02 15:04:05 MST" }}</small>
+   </div>
+  </body>
+</html>
+`
+
+// statusHandler serves a public status page. It must stay reachable
+// without authentication.
+func statusHandler(c *gin.Context) {
+	page, err := statuspage.Get(c.Param("name"))
+	if err == statuspage.ErrorUnknownPage {
+		c.AbortWithError(404, err)
+		return
+	} else if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(200, page)
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = statusTemplate.Execute(c.Writer, page)
+	if err != nil {
+		c.AbortWithError(500, err)
+	}
+}
+
+func statusRoutes(router *gin.Engine) {
+	router.GET("/status/:name", statusHandler)
+
+	a := router.Group("/announcement")
+	{
+		a.GET("/", require(auth.Viewer), func(c *gin.Context) {
+			announcements, err := statuspage.GetAnnouncements(c.Query("page"), true)
+			if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, announcements)
+			}
+		})
+
+		a.POST("/new", require(auth.Operator), func(c *gin.Context) {
+			var announcement statuspage.Announcement
+			c.Bind(&announcement)
+
+			err := statuspage.AddAnnouncement(&announcement)
+			if err == statuspage.ErrorUnknownPage {
+				c.AbortWithError(404, err)
+			} else if err != nil {
+				c.AbortWithError(400, err)
+			} else {
+				record(c, "announcementadd", announcement.Id.Hex(), nil, announcement)
+				c.JSON(200, announcement)
+			}
+		})
+
+		a.DELETE("/:id", require(auth.Operator), func(c *gin.Context) {
+			id := c.Param("id")
+
+			err := statuspage.DeleteAnnouncement(id)
+			if err == statuspage.ErrorInvalidId {
+				c.AbortWithError(400, err)
+			} else if err == mgo.ErrNotFound {
+				c.AbortWithError(404, err)
+			} else if err != nil {
+				c.AbortWithError(500, err)
+			} else {
+				record(c, "announcementdelete", id, nil, nil)
+				c.JSON(200, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// certificateReloader holds a certificate that can be replaced while
+	// serving.
+	certificateReloader struct {
+		sync.RWMutex
+		certFile string
+		keyFile  string
+		cert     *tls.Certificate
+	}
+)
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the certificate and key from disk. The current certificate
+// is kept if they cannot be read.
+func (r *certificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.cert = &cert
+	r.Unlock()
+
+	return nil
+}
+
+// ReloadOnSignal reloads the certificate every time sig is received.
+func (r *certificateReloader) ReloadOnSignal(sig os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+
+	go func() {
+		for range ch {
+			err := r.Reload()
+			if err != nil {
+				logger.Error("api", "Error reloading certificate: %s", err.Error())
+			} else {
+				logger.Green("api", "Reloaded certificate %s", r.certFile)
+			}
+		}
+	}()
+}
+
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.cert, nil
+}
+
+// newTLSConfig builds a server TLS configuration from c.
+func newTLSConfig(c *config.TLS) (*tls.Config, *certificateReloader, error) {
+	reloader, err := newCertificateReloader(c.Certificate, c.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch c.ClientAuth {
+	case "", "none":
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case "request":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, nil, fmt.Errorf("unknown clientAuth '%s'", c.ClientAuth)
+	}
+
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		pem, err := ioutil.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in %s", c.ClientCA)
+		}
+
+		tlsConfig.ClientCAs = pool
+	}
+
+	if c.ClientRole != "" && !auth.Role(c.ClientRole).Valid() {
+		return nil, nil, fmt.Errorf("unknown clientRole '%s'", c.ClientRole)
+	}
+
+	return tlsConfig, reloader, nil
+}
+
+// redirectHandler redirects plain HTTP requests to the HTTPS listener at
+// listen.
+func redirectHandler(listen string) http.Handler {
+	_, port, _ := net.SplitHostPort(listen)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// serve serves handler according to the API configuration, with TLS if
+// configured.
+func serve(c config.API, handler http.Handler) error {
+	server := &http.Server{
+		Addr:    c.Listen,
+		Handler: handler,
+	}
+
+	if c.TLS == nil {
+		logger.Yellow("api", "Serving plain HTTP on %s", c.Listen)
+		return server.ListenAndServe()
+	}
+
+	tlsConfig, reloader, err := newTLSConfig(c.TLS)
+	if err != nil {
+		return err
+	}
+
+	reloader.ReloadOnSignal(syscall.SIGHUP)
+	server.TLSConfig = tlsConfig
+
+	if c.TLS.RedirectListen != "" {
+		go func() {
+			logger.Yellow("api", "Redirecting HTTP on %s to HTTPS", c.TLS.RedirectListen)
+			err := http.ListenAndServe(c.TLS.RedirectListen, redirectHandler(c.Listen))
+			if err != nil {
+				logger.Error("api", "Redirect listener failed: %s", err.Error())
+			}
+		}()
+	}
+
+	logger.Yellow("api", "Serving HTTPS on %s", c.Listen)
+
+	// Certificates are provided by tlsConfig.GetCertificate.
+	return server.ListenAndServeTLS("", "")
+}
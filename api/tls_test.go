@@ -0,0 +1,200 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abrander/alerto/auth"
+	"github.com/abrander/alerto/config"
+)
+
+type (
+	testCert struct {
+		cert *x509.Certificate
+		key  *ecdsa.PrivateKey
+		der  []byte
+	}
+)
+
+// newTestCert returns a certificate for name signed by parent, or a self
+// signed CA if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	signer := &testCert{cert: template, key: key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+		signer = parent
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() returned %s", err.Error())
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and key as PEM files in dir.
+func (c *testCert) write(t *testing.T, dir string) (string, string) {
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() returned %s", err.Error())
+	}
+
+	certFile := filepath.Join(dir, c.cert.Subject.CommonName+".crt")
+	keyFile := filepath.Join(dir, c.cert.Subject.CommonName+".key")
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "ca", nil, 0)
+	server := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	stranger := newTestCert(t, "stranger", newTestCert(t, "other-ca", nil, 0), x509.ExtKeyUsageClientAuth)
+
+	caFile, _ := ca.write(t, dir)
+	certFile, keyFile := server.write(t, dir)
+
+	c := &config.TLS{
+		Certificate: certFile,
+		Key:         keyFile,
+		ClientAuth:  "request",
+		ClientCA:    caFile,
+		ClientRole:  string(auth.Operator),
+	}
+
+	tlsConfig, _, err := newTLSConfig(c)
+	if err != nil {
+		t.Fatalf("newTLSConfig() returned %s", err.Error())
+	}
+
+	saved := config.Current.API.TLS
+	config.Current.API.TLS = c
+	defer func() { config.Current.API.TLS = saved }()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), 401)
+			return
+		}
+
+		w.Header().Set("X-Principal", p.Name)
+		w.Header().Set("X-Role", string(p.Role))
+	}))
+	// StartTLS would replace the certificate of tlsConfig with its own.
+	ts.Listener = tls.NewListener(ts.Listener, tlsConfig)
+	ts.Start()
+	defer ts.Close()
+
+	url := "https://" + ts.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	cases := []struct {
+		name      string
+		cert      *testCert
+		code      int
+		principal string
+	}{
+		{"verified", client, 200, "certificate:client"},
+		{"no certificate", nil, 401, ""},
+	}
+
+	for _, tc := range cases {
+		tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+		if tc.cert != nil {
+			tr.TLSClientConfig.Certificates = []tls.Certificate{tc.cert.tlsCertificate()}
+		}
+
+		resp, err := (&http.Client{Transport: tr}).Get(url)
+		if err != nil {
+			t.Fatalf("%s: Get() returned %s", tc.name, err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s: Expected %d, got %d", tc.name, tc.code, resp.StatusCode)
+		}
+
+		if tc.principal != "" && (resp.Header.Get("X-Principal") != tc.principal || resp.Header.Get("X-Role") != string(auth.Operator)) {
+			t.Errorf("%s: Expected %s with role %s, got %s with role %s", tc.name, tc.principal, auth.Operator, resp.Header.Get("X-Principal"), resp.Header.Get("X-Role"))
+		}
+	}
+
+	// A certificate from another CA is rejected in the handshake. It's
+	// sent even though the server doesn't ask for its CA.
+	tr := &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert := stranger.tlsCertificate()
+			return &cert, nil
+		},
+	}}
+
+	resp, err := (&http.Client{Transport: tr}).Get(url)
+	if err == nil {
+		resp.Body.Close()
+		t.Errorf("Certificate from unknown CA was accepted")
+	}
+}
+
+func TestTLSConfigClientRole(t *testing.T) {
+	dir := t.TempDir()
+
+	server := newTestCert(t, "server", newTestCert(t, "ca", nil, 0), x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := server.write(t, dir)
+
+	_, _, err := newTLSConfig(&config.TLS{Certificate: certFile, Key: keyFile, ClientRole: "root"})
+	if err == nil {
+		t.Errorf("Unknown client role accepted")
+	}
+
+	_, _, err = newTLSConfig(&config.TLS{Certificate: certFile, Key: keyFile, ClientRole: string(auth.Admin)})
+	if err != nil {
+		t.Errorf("newTLSConfig() returned %s", err.Error())
+	}
+}
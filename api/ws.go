@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/abrander/alerto/monitor"
+)
+
+type (
+	// clientMessage is sent from websocket clients. Id is echoed in the
+	// reply to allow clients to correlate them.
+	clientMessage struct {
+		Id      string          `json:"id"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	reply struct {
+		Ok     bool        `json:"ok"`
+		Error  string      `json:"error,omitempty"`
+		Result interface{} `json:"result,omitempty"`
+	}
+)
+
+const (
+	// Time allowed to write a message to the client.
+	writeWait = 10 * time.Second
+
+	// A client not answering pings for this long is considered dead.
+	pongWait = 60 * time.Second
+
+	// Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	maxClientMessageSize = 64 * 1024
+)
+
+// readLoop reads messages from conn until it fails or done is closed. The
+// returned channel is closed when the connection is no longer usable.
+func readLoop(conn *websocket.Conn, done chan struct{}) chan clientMessage {
+	incoming := make(chan clientMessage)
+
+	conn.SetReadLimit(maxClientMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	go func() {
+		defer close(incoming)
+
+		for {
+			var msg clientMessage
+			err := conn.ReadJSON(&msg)
+			if _, ok := err.(*json.SyntaxError); ok {
+				continue
+			} else if err != nil {
+				return
+			}
+
+			select {
+			case incoming <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return incoming
+}
+
+// handleClientMessage answers a single message from a client. filter is
+// replaced by subscribe messages.
+func handleClientMessage(c *gin.Context, msg clientMessage, filter *monitor.Filter) Message {
+	r := reply{}
+
+	switch msg.Type {
+	case "subscribe":
+		var f monitor.Filter
+		err := json.Unmarshal(msg.Payload, &f)
+		if err == nil {
+			err = f.Compile()
+		}
+
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			*filter = f
+			r.Ok = true
+			r.Result = f
+		}
+	default:
+		var cmd command
+		err := json.Unmarshal(msg.Payload, &cmd)
+		if err == nil {
+			r.Result, err = runCommand(c, msg.Type, cmd)
+		}
+
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Ok = true
+		}
+	}
+
+	return Message{Id: msg.Id, Type: "reply", Payload: r}
+}
+
+func wshandler(c *gin.Context) {
+	var since uint64
+	if c.Query("since") != "" {
+		var err error
+		since, err = strconv.ParseUint(c.Query("since"), 10, 64)
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	conn, err := wsupgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	write := func(msg interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(msg)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	pinger := time.NewTicker(pingPeriod)
+	defer pinger.Stop()
+
+	// A slow client is disconnected rather than allowed to lag behind. It
+	// can reconnect with ?since= to catch up.
+	changes, err := monitor.Subscribe(since, monitor.Disconnect)
+	defer monitor.Unsubscribe(changes)
+
+	if err == monitor.ErrorSequenceTooOld {
+		err = write(Message{Type: "resync", Payload: monitor.Sequence()})
+		if err != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	incoming := readLoop(conn, done)
+	filter := monitor.Filter{}
+	filter.Compile()
+
+	status := Status{
+		Started: StartTime,
+	}
+
+	for {
+		select {
+		case t := <-ticker.C:
+			status.Clock = t
+			status.Uptime = t.Sub(StartTime)
+			status.Seq = monitor.Sequence()
+			err := write(Message{Type: "status", Payload: status})
+			if err != nil {
+				return
+			}
+		case <-pinger.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			if err != nil {
+				return
+			}
+		case msg, ok := <-incoming:
+			if !ok {
+				return
+			}
+
+			err := write(handleClientMessage(c, msg, &filter))
+			if err != nil {
+				return
+			}
+		case msg, ok := <-changes.C:
+			if !ok {
+				return
+			}
+
+			if !filter.MatchChange(msg) {
+				continue
+			}
+
+			err := write(msg)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
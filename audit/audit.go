@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Entry describes a single configuration change. Entries are never
+	// updated or removed.
+	Entry struct {
+		Id       bson.ObjectId `json:"id" bson:"_id"`
+		Time     time.Time     `json:"time"`
+		Type     string        `json:"type"`
+		ObjectId string        `json:"objectId" bson:"objectId"`
+		Actor    string        `json:"actor"`
+		SourceIP string        `json:"sourceIp" bson:"sourceIp"`
+		Before   interface{}   `json:"before"`
+		After    interface{}   `json:"after"`
+	}
+
+	// Filter selects entries. Zero values match everything.
+	Filter struct {
+		Type     string
+		ObjectId string
+		Actor    string
+		Since    time.Time
+		Until    time.Time
+		Limit    int
+	}
+)
+
+const (
+	DefaultLimit = 100
+)
+
+var (
+	auditCollection *mgo.Collection
+)
+
+func init() {
+	sess, err := mgo.Dial("127.0.0.1")
+	if err != nil {
+		logger.Error("audit", "Can't connect to mongo, go error %v", err)
+		os.Exit(1)
+	}
+
+	auditCollection = sess.DB("alerto").C("audit")
+	auditCollection.EnsureIndex(mgo.Index{Key: []string{"-time"}})
+	auditCollection.EnsureIndex(mgo.Index{Key: []string{"objectId", "-time"}})
+}
+
+// Record appends entry to the audit log. Id and Time are set if missing.
+func Record(entry Entry) error {
+	if entry.Id == "" {
+		entry.Id = bson.NewObjectId()
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	logger.Printf("audit", "%s %s by %s from %s", entry.Type, entry.ObjectId, entry.Actor, entry.SourceIP)
+
+	return auditCollection.Insert(entry)
+}
+
+// Find returns entries matching filter, newest first.
+func Find(filter Filter) ([]Entry, error) {
+	query := bson.M{}
+
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+
+	if filter.ObjectId != "" {
+		query["objectId"] = filter.ObjectId
+	}
+
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		t := bson.M{}
+
+		if !filter.Since.IsZero() {
+			t["$gte"] = filter.Since
+		}
+
+		if !filter.Until.IsZero() {
+			t["$lt"] = filter.Until
+		}
+
+		query["time"] = t
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	entries := []Entry{}
+	err := auditCollection.Find(query).Sort("-time").Limit(limit).All(&entries)
+
+	return entries, err
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+
+	"gopkg.in/mgo.v2"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Role decides what a principal is allowed to do. Each role includes the
+	// permissions of the roles below it.
+	Role string
+
+	// Principal is the authenticated party behind a request.
+	Principal struct {
+		Name string `json:"name"`
+		Role Role   `json:"role"`
+		Kind string `json:"kind"`
+	}
+)
+
+const (
+	Viewer   Role = "viewer"
+	Operator Role = "operator"
+	Admin    Role = "admin"
+
+	// bootstrapPasswordFilename in config.ConfigDir holds the password of
+	// the admin user created at first startup.
+	bootstrapPasswordFilename = "admin-password"
+)
+
+var (
+	db                *mgo.Database
+	userCollection    *mgo.Collection
+	sessionCollection *mgo.Collection
+	tokenCollection   *mgo.Collection
+
+	ErrorInvalidId          error = errors.New("Invalid id")
+	ErrorInvalidRole        error = errors.New("Invalid role")
+	ErrorInvalidCredentials error = errors.New("Invalid username or password")
+	ErrorUnauthenticated    error = errors.New("Authentication required")
+	ErrorForbidden          error = errors.New("Permission denied")
+
+	roleLevels = map[Role]int{
+		Viewer:   1,
+		Operator: 2,
+		Admin:    3,
+	}
+)
+
+func init() {
+	sess, err := mgo.Dial("127.0.0.1")
+	if err != nil {
+		logger.Error("auth", "Can't connect to mongo, go error %v", err)
+		os.Exit(1)
+	}
+
+	db = sess.DB("alerto")
+	userCollection = db.C("users")
+	sessionCollection = db.C("sessions")
+	tokenCollection = db.C("tokens")
+
+	userCollection.EnsureIndex(mgo.Index{Key: []string{"username"}, Unique: true})
+	sessionCollection.EnsureIndex(mgo.Index{Key: []string{"expires"}, ExpireAfter: 1})
+	tokenCollection.EnsureIndex(mgo.Index{Key: []string{"hash"}, Unique: true})
+
+	bootstrap()
+
+	err = LoadBadgeKey()
+	if err != nil {
+		logger.Error("auth", "Can't load badge key: %s", err.Error())
+		os.Exit(1)
+	}
+}
+
+// bootstrap creates an admin user with a random password if no users
+// exist, so a fresh installation can be logged into.
+func bootstrap() {
+	n, err := userCollection.Count()
+	if err != nil {
+		logger.Error("auth", "Error counting users: %s", err.Error())
+		return
+	}
+
+	if n > 0 {
+		return
+	}
+
+	password := randomString(12)
+
+	// The password is kept out of the log, which may be readable by
+	// others or shipped elsewhere. Without the file nobody could log in,
+	// so the user is only created once it's written.
+	filename := path.Join(config.ConfigDir, bootstrapPasswordFilename)
+
+	err = writePrivateFile(filename, password+"\n")
+	if err != nil {
+		logger.Error("auth", "Error writing %s, admin user not created: %s", filename, err.Error())
+		return
+	}
+
+	_, err = AddUser("admin", password, Admin)
+	if err != nil {
+		logger.Error("auth", "Error creating admin user: %s", err.Error())
+		os.Remove(filename)
+		return
+	}
+
+	logger.Yellow("auth", "Created user 'admin' with the password in %s. Please change it and remove the file.", filename)
+}
+
+// writePrivateFile writes content to a new file readable only by the
+// owner. An existing file is replaced, since it could be readable by
+// others.
+func writePrivateFile(filename string, content string) error {
+	err := os.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Valid returns true if r is a known role.
+func (r Role) Valid() bool {
+	_, found := roleLevels[r]
+
+	return found
+}
+
+// Allows returns true if a principal with role r may perform actions
+// requiring the role required.
+func (r Role) Allows(required Role) bool {
+	return roleLevels[r] >= roleLevels[required]
+}
+
+// Allows returns true if the principal is allowed to perform actions
+// requiring the role required.
+func (p *Principal) Allows(required Role) bool {
+	return p != nil && p.Role.Allows(required)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// hash is used for secrets that are random enough to not require a slow
+// hash, such as session ids and API tokens.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
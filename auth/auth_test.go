@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWritePrivateFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "admin-password")
+
+	// An existing file readable by others must not be reused.
+	err := os.WriteFile(filename, []byte("old"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() returned %s", err.Error())
+	}
+
+	err = writePrivateFile(filename, "secret\n")
+	if err != nil {
+		t.Fatalf("writePrivateFile() returned %s", err.Error())
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Stat() returned %s", err.Error())
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "secret\n" {
+		t.Errorf("Wrong content %q", content)
+	}
+}
+
+func TestLoadBadgeKey(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "badge.key")
+
+	created, err := loadBadgeKey(filename)
+	if err != nil {
+		t.Fatalf("loadBadgeKey() returned %s", err.Error())
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil || info.Mode().Perm() != 0600 || len(created) != 32 {
+		t.Fatalf("Key not written, %v, %v", info, err)
+	}
+
+	key, err := loadBadgeKey(filename)
+	if err != nil || string(key) != string(created) {
+		t.Errorf("Key not reused")
+	}
+
+	_, err = loadBadgeKey(filepath.Join(t.TempDir(), "missing", "badge.key"))
+	if err == nil {
+		t.Errorf("No error when the key can't be written")
+	}
+}
+
+func TestValidBadgeToken(t *testing.T) {
+	badgeKey = nil
+	if ValidBadgeToken("monitor:1", BadgeToken("monitor:1")) {
+		t.Errorf("Token accepted without a key")
+	}
+
+	badgeKey = make([]byte, 32)
+	defer func() { badgeKey = nil }()
+
+	token := BadgeToken("monitor:1")
+	if !ValidBadgeToken("monitor:1", token) {
+		t.Errorf("Token rejected")
+	}
+
+	if ValidBadgeToken("monitor:2", token) {
+		t.Errorf("Token accepted for another subject")
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+)
+
+const (
+	badgeKeyFilename = "badge.key"
+)
+
+var (
+	badgeKey []byte
+)
+
+// LoadBadgeKey reads the key used for signing badge tokens from the
+// configuration directory, creating it if it does not exist. Deleting the
+// file revokes all badge tokens. It must be called before any token is
+// issued or checked.
+func LoadBadgeKey() error {
+	key, err := loadBadgeKey(path.Join(config.ConfigDir, badgeKeyFilename))
+	if err != nil {
+		return err
+	}
+
+	badgeKey = key
+
+	return nil
+}
+
+func loadBadgeKey(filename string) ([]byte, error) {
+	key, err := ioutil.ReadFile(filename)
+	if err == nil && len(key) >= 32 {
+		return key, nil
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error("auth", "Error reading badge key: %s", err.Error())
+	}
+
+	key = make([]byte, 32)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key that's lost at restart would make all tokens stop working.
+	err = writePrivateFile(filename, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func badgeMAC(subject string) []byte {
+	mac := hmac.New(sha256.New, badgeKey)
+	mac.Write([]byte(subject))
+
+	return mac.Sum(nil)[:16]
+}
+
+// BadgeToken returns the token allowing anonymous access to the badge for
+// subject, like "monitor:<id>".
+func BadgeToken(subject string) string {
+	return hex.EncodeToString(badgeMAC(subject))
+}
+
+// ValidBadgeToken returns true if token grants access to the badge for
+// subject.
+func ValidBadgeToken(subject string, token string) bool {
+	// Without a key anyone could compute tokens.
+	if len(badgeKey) == 0 {
+		return false
+	}
+
+	t, err := hex.DecodeString(token)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(t, badgeMAC(subject))
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type (
+	session struct {
+		Id      string        `bson:"_id"`
+		UserId  bson.ObjectId `bson:"userId"`
+		Expires time.Time     `bson:"expires"`
+	}
+)
+
+const (
+	SessionLifetime = time.Hour * 24 * 7
+)
+
+// NewSession starts a session for user and returns the secret session id
+// to hand to the client. Only a hash of the id is stored.
+func NewSession(user User) (string, error) {
+	id := randomString(32)
+
+	s := session{
+		Id:      hash(id),
+		UserId:  user.Id,
+		Expires: time.Now().Add(SessionLifetime),
+	}
+
+	return id, sessionCollection.Insert(s)
+}
+
+// SessionPrincipal looks up the principal for a session id returned by
+// NewSession.
+func SessionPrincipal(id string) (*Principal, error) {
+	var s session
+
+	err := sessionCollection.FindId(hash(id)).One(&s)
+	if err == mgo.ErrNotFound {
+		return nil, ErrorUnauthenticated
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Mongo only expires documents every minute or so.
+	if time.Now().After(s.Expires) {
+		return nil, ErrorUnauthenticated
+	}
+
+	var user User
+	err = userCollection.FindId(s.UserId).One(&user)
+	if err == mgo.ErrNotFound {
+		return nil, ErrorUnauthenticated
+	} else if err != nil {
+		return nil, err
+	}
+
+	return user.Principal(), nil
+}
+
+func EndSession(id string) error {
+	err := sessionCollection.RemoveId(hash(id))
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Token is an API token meant for automation. The secret itself is only
+	// available when the token is created.
+	Token struct {
+		Id       bson.ObjectId `json:"id" bson:"_id"`
+		Name     string        `json:"name"`
+		Role     Role          `json:"role"`
+		Hash     string        `json:"-"`
+		Created  time.Time     `json:"created"`
+		LastUsed time.Time     `json:"lastUsed" bson:"lastUsed"`
+	}
+)
+
+// AddToken creates a new API token and returns it along with the secret
+// to be used in the Authorization header.
+func AddToken(name string, role Role) (Token, string, error) {
+	secret := randomString(32)
+
+	token := Token{
+		Id:      bson.NewObjectId(),
+		Name:    name,
+		Role:    role,
+		Hash:    hash(secret),
+		Created: time.Now(),
+	}
+
+	if !role.Valid() {
+		return token, "", ErrorInvalidRole
+	}
+
+	return token, secret, tokenCollection.Insert(token)
+}
+
+func GetAllTokens() []Token {
+	var tokens []Token
+
+	err := tokenCollection.Find(bson.M{}).All(&tokens)
+	if err != nil {
+		logger.Red("auth", "Error getting tokens from Mongo: %s", err.Error())
+	}
+
+	return tokens
+}
+
+func DeleteToken(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrorInvalidId
+	}
+
+	return tokenCollection.RemoveId(bson.ObjectIdHex(id))
+}
+
+// TokenPrincipal looks up the principal for the secret returned by
+// AddToken.
+func TokenPrincipal(secret string) (*Principal, error) {
+	var token Token
+
+	err := tokenCollection.Find(bson.M{"hash": hash(secret)}).One(&token)
+	if err == mgo.ErrNotFound {
+		return nil, ErrorUnauthenticated
+	} else if err != nil {
+		return nil, err
+	}
+
+	tokenCollection.UpdateId(token.Id, bson.M{"$set": bson.M{"lastUsed": time.Now()}})
+
+	return &Principal{
+		Name: "token:" + token.Name,
+		Role: token.Role,
+		Kind: "token",
+	}, nil
+}
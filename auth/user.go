@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	User struct {
+		Id           bson.ObjectId `json:"id" bson:"_id"`
+		Username     string        `json:"username"`
+		PasswordHash []byte        `json:"-" bson:"passwordHash"`
+		Role         Role          `json:"role"`
+		Created      time.Time     `json:"created"`
+	}
+)
+
+func AddUser(username string, password string, role Role) (User, error) {
+	user := User{
+		Id:       bson.NewObjectId(),
+		Username: username,
+		Role:     role,
+		Created:  time.Now(),
+	}
+
+	if !role.Valid() {
+		return user, ErrorInvalidRole
+	}
+
+	err := user.SetPassword(password)
+	if err != nil {
+		return user, err
+	}
+
+	return user, userCollection.Insert(user)
+}
+
+func (user *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+
+	return nil
+}
+
+func GetAllUsers() []User {
+	var users []User
+
+	err := userCollection.Find(bson.M{}).All(&users)
+	if err != nil {
+		logger.Red("auth", "Error getting users from Mongo: %s", err.Error())
+	}
+
+	return users
+}
+
+func GetUser(id string) (User, error) {
+	var user User
+
+	if !bson.IsObjectIdHex(id) {
+		return user, ErrorInvalidId
+	}
+
+	err := userCollection.FindId(bson.ObjectIdHex(id)).One(&user)
+
+	return user, err
+}
+
+func UpdateUser(user *User) error {
+	if !user.Role.Valid() {
+		return ErrorInvalidRole
+	}
+
+	return userCollection.UpdateId(user.Id, user)
+}
+
+func DeleteUser(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrorInvalidId
+	}
+
+	_, err := sessionCollection.RemoveAll(bson.M{"userId": bson.ObjectIdHex(id)})
+	if err != nil {
+		return err
+	}
+
+	return userCollection.RemoveId(bson.ObjectIdHex(id))
+}
+
+// Authenticate checks a username and password. The same error is returned
+// for unknown users and wrong passwords.
+func Authenticate(username string, password string) (User, error) {
+	var user User
+
+	err := userCollection.Find(bson.M{"username": username}).One(&user)
+	if err == mgo.ErrNotFound {
+		return user, ErrorInvalidCredentials
+	} else if err != nil {
+		return user, err
+	}
+
+	err = bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password))
+	if err != nil {
+		return user, ErrorInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (user *User) Principal() *Principal {
+	return &Principal{
+		Name: user.Username,
+		Role: user.Role,
+		Kind: "user",
+	}
+}
@@ -1,13 +1,170 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"path"
+	"time"
 
 	"github.com/abrander/alerto/logger"
 )
 
+type (
+	// Config is read from alerto.json in ConfigDir.
+	Config struct {
+		API       API        `json:"api"`
+		Exporters []Exporter `json:"exporters"`
+		Notifiers []Notifier `json:"notifiers"`
+		Reports   []Report   `json:"reports"`
+		Incidents Incidents  `json:"incidents"`
+
+		StatusPages []StatusPage `json:"statusPages"`
+		Plugins     Plugins      `json:"plugins"`
+	}
+
+	// Plugins configures agents running as external executables.
+	Plugins struct {
+		// Directory is searched for executables at startup. It defaults
+		// to "plugins" in ConfigDir.
+		Directory string `json:"directory"`
+
+		// Limits for each run of an executable. Zero means no limit.
+		MemoryLimit int64    `json:"memoryLimit"`
+		CPULimit    Duration `json:"cpuLimit"`
+		OutputLimit int64    `json:"outputLimit"`
+	}
+
+	Incidents struct {
+		// Notifiers are the names of the notifiers told about incidents
+		// being opened and closed.
+		Notifiers []string `json:"notifiers"`
+	}
+
+	API struct {
+		Listen string `json:"listen"`
+		TLS    *TLS   `json:"tls"`
+	}
+
+	TLS struct {
+		Certificate string `json:"certificate"`
+		Key         string `json:"key"`
+
+		// RedirectListen is an optional address for a plain HTTP listener
+		// redirecting everything to HTTPS.
+		RedirectListen string `json:"redirectListen"`
+
+		// ClientAuth is one of "none", "request" and "require".
+		ClientAuth string `json:"clientAuth"`
+		ClientCA   string `json:"clientCA"`
+
+		// ClientRole is the role given to clients presenting a verified
+		// certificate, one of "viewer", "operator" and "admin". If empty,
+		// certificates are not used for authentication.
+		ClientRole string `json:"clientRole"`
+	}
+
+	// Exporter configures a sink receiving the results of all checks.
+	Exporter struct {
+		Name string `json:"name"`
+
+		// Type is one of "influxdb-http", "influxdb-udp" and "graphite".
+		Type string `json:"type"`
+
+		// Address is a URL for influxdb-http and host:port for the others.
+		Address  string `json:"address"`
+		Database string `json:"database"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+
+		// Template names the series. It can contain {host}, {agent},
+		// {key}, {monitor} and {name}, which is the monitor id for unnamed
+		// monitors.
+		Template string `json:"template"`
+
+		BatchSize     int      `json:"batchSize"`
+		FlushInterval Duration `json:"flushInterval"`
+
+		// MaxBufferSize limits the number of bytes buffered on disk while
+		// the sink is unavailable.
+		MaxBufferSize int64 `json:"maxBufferSize"`
+
+		// BufferDirectory holds the disk buffer. It defaults to StateDir.
+		BufferDirectory string `json:"bufferDirectory"`
+	}
+
+	// Notifier configures a way of telling humans or other systems about
+	// something.
+	Notifier struct {
+		Name string `json:"name"`
+
+		// Type is one of "webhook", "email" and "log".
+		Type string `json:"type"`
+
+		// URL is used by webhook notifiers.
+		URL string `json:"url"`
+
+		// Used by email notifiers. Address is the host:port of a SMTP
+		// server.
+		Address  string   `json:"address"`
+		From     string   `json:"from"`
+		To       []string `json:"to"`
+		Username string   `json:"username"`
+		Password string   `json:"password"`
+	}
+
+	// Report is an availability report produced periodically.
+	Report struct {
+		Name string `json:"name"`
+
+		// Period is one of "day", "week" and "month". The report covers the
+		// previous period and is produced when a new one starts.
+		Period string `json:"period"`
+
+		// GroupBy is "monitor", "host" or "label:<key>".
+		GroupBy string `json:"groupBy"`
+		Labels  string `json:"labels"`
+
+		// Notifier is the name of the notifier receiving the report.
+		Notifier string `json:"notifier"`
+	}
+
+	// StatusPage is a public page showing the status of components.
+	StatusPage struct {
+		// Name is used in the path, /status/<name>.
+		Name       string      `json:"name"`
+		Title      string      `json:"title"`
+		Components []Component `json:"components"`
+	}
+
+	// Component is a named group of monitors shown on a status page. It
+	// consists of the monitors listed in Monitors and those matching the
+	// label selector in Labels.
+	Component struct {
+		Name     string   `json:"name"`
+		Monitors []string `json:"monitors"`
+		Labels   string   `json:"labels"`
+	}
+
+	// Duration is a time.Duration read from strings like "10s".
+	Duration struct {
+		time.Duration
+	}
+)
+
 const (
-	ConfigDir = "/etc/alerto"
+	ConfigDir      = "/etc/alerto"
+	ConfigFilename = "alerto.json"
+
+	// StateDir is for files written at runtime.
+	StateDir = "/var/lib/alerto"
+)
+
+var (
+	Current = Config{
+		API: API{
+			Listen: ":9901",
+		},
+	}
 )
 
 func init() {
@@ -17,4 +174,39 @@ func init() {
 		gid := os.Getgid()
 		logger.Error("config", "Please run:\nsudo mkdir -p %s && sudo chown %d.%d %s\n", ConfigDir, uid, gid, ConfigDir)
 	}
+
+	err = Load(path.Join(ConfigDir, ConfigFilename))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error("config", "Error reading configuration: %s", err.Error())
+	}
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		// Allow plain nanoseconds.
+		return json.Unmarshal(data, &d.Duration)
+	}
+
+	d.Duration, err = time.ParseDuration(s)
+
+	return err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// Load reads configuration from filename into Current. Values not present
+// in the file are left untouched.
+func Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewDecoder(f).Decode(&Current)
 }
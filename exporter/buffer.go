@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// diskBuffer keeps points on disk as JSON lines while a sink is down.
+	diskBuffer struct {
+		sync.Mutex
+		filename string
+		maxSize  int64
+	}
+)
+
+func newDiskBuffer(filename string, maxSize int64) *diskBuffer {
+	return &diskBuffer{
+		filename: filename,
+		maxSize:  maxSize,
+	}
+}
+
+func (b *diskBuffer) Empty() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	info, err := os.Stat(b.filename)
+
+	return err != nil || info.Size() == 0
+}
+
+// Append adds points to the buffer. Points are discarded if the buffer
+// would grow beyond its maximum size.
+func (b *diskBuffer) Append(points []Point) error {
+	b.Lock()
+	defer b.Unlock()
+
+	f, err := os.OpenFile(b.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() >= b.maxSize {
+		return fmt.Errorf("disk buffer %s is full, discarding %d points", b.filename, len(points))
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range points {
+		err = enc.Encode(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Replay writes all buffered points in batches. If a batch fails, it and
+// the points following it are kept for the next replay.
+func (b *diskBuffer) Replay(batchSize int, write func([]Point) error) error {
+	b.Lock()
+	defer b.Unlock()
+
+	f, err := os.Open(b.filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	batch := make([]Point, 0, batchSize)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			break
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var p Point
+		decodeErr := json.Unmarshal(line, &p)
+		if decodeErr != nil {
+			// A partially written line is lost, the next one can be used.
+			logger.Red("exporter", "Skipping unreadable line in %s: %s", b.filename, decodeErr.Error())
+			continue
+		}
+
+		batch = append(batch, p)
+		if len(batch) >= batchSize {
+			err = write(batch)
+			if err != nil {
+				return b.keep(batch, r, err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		err = write(batch)
+		if err != nil {
+			return b.keep(batch, nil, err)
+		}
+	}
+
+	return os.Truncate(b.filename, 0)
+}
+
+// keep replaces the buffer with batch followed by rest, if not nil, and
+// returns cause. It must be called with the lock held.
+func (b *diskBuffer) keep(batch []Point, rest io.Reader, cause error) error {
+	tmp := b.filename + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return cause
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range batch {
+		enc.Encode(p)
+	}
+
+	if rest != nil {
+		_, err = io.Copy(w, rest)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	f.Close()
+
+	if err != nil {
+		os.Remove(tmp)
+		return cause
+	}
+
+	os.Rename(tmp, b.filename)
+
+	return cause
+}
@@ -0,0 +1,379 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Point is a single measurement with tags, as understood by most time
+	// series databases.
+	Point struct {
+		Name   string             `json:"name"`
+		Tags   map[string]string  `json:"tags"`
+		Fields map[string]float64 `json:"fields"`
+		Time   time.Time          `json:"time"`
+	}
+
+	// Sink writes points to an external system.
+	Sink interface {
+		Write(points []Point) error
+		Close() error
+	}
+
+	// Exporter batches points for a sink and buffers them on disk while
+	// the sink is failing.
+	Exporter struct {
+		name      string
+		sink      Sink
+		template  string
+		perKey    bool
+		batchSize int
+		interval  time.Duration
+		queue     chan Point
+		buffer    *diskBuffer
+	}
+
+	// permanentError is returned by sinks rejecting points. Writing the
+	// same points again would fail the same way, so they are not
+	// buffered.
+	permanentError struct {
+		err error
+	}
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = 10 * time.Second
+	defaultMaxBufferSize = 64 * 1024 * 1024
+	queueSize            = 10000
+)
+
+var (
+	// reservedTags are set by the exporter. Labels with the same names
+	// are prefixed with "label_".
+	reservedTags = map[string]bool{
+		"host":    true,
+		"agent":   true,
+		"monitor": true,
+		"name":    true,
+		"unit":    true,
+	}
+)
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// isPermanent returns true if err was returned for points that should
+// not be retried.
+func isPermanent(err error) bool {
+	_, ok := err.(permanentError)
+
+	return ok
+}
+
+// NewSink creates a sink from its configuration.
+func NewSink(c config.Exporter) (Sink, error) {
+	switch c.Type {
+	case "influxdb-http":
+		return NewInfluxHTTP(c.Address, c.Database, c.Username, c.Password), nil
+	case "influxdb-udp":
+		return NewInfluxUDP(c.Address)
+	case "graphite":
+		return NewGraphite(c.Address), nil
+	}
+
+	return nil, fmt.Errorf("unknown exporter type '%s'", c.Type)
+}
+
+// New creates an exporter for sink. The exporter does nothing until Run is
+// called.
+func New(c config.Exporter, sink Sink) *Exporter {
+	e := &Exporter{
+		name:      c.Name,
+		sink:      sink,
+		template:  c.Template,
+		batchSize: c.BatchSize,
+		interval:  c.FlushInterval.Duration,
+		queue:     make(chan Point, queueSize),
+	}
+
+	if e.template == "" {
+		if c.Type == "graphite" {
+			e.template = "alerto.{host}.{agent}.{name}.{key}"
+		} else {
+			e.template = "alerto_{agent}"
+		}
+	}
+
+	// Without {key} in the template, Graphite appends the key to the
+	// series name itself.
+	e.perKey = strings.Contains(e.template, "{key}")
+
+	if e.batchSize <= 0 {
+		e.batchSize = defaultBatchSize
+	}
+
+	if e.interval <= 0 {
+		e.interval = defaultFlushInterval
+	}
+
+	maxBufferSize := c.MaxBufferSize
+	if maxBufferSize <= 0 {
+		maxBufferSize = defaultMaxBufferSize
+	}
+
+	dir := c.BufferDirectory
+	if dir == "" {
+		dir = config.StateDir
+	}
+
+	e.buffer = newDiskBuffer(path.Join(dir, "exporter-"+c.Name+".buffer"), maxBufferSize)
+
+	return e
+}
+
+// Start creates exporters from the configuration and registers them for
+// results from the scheduler.
+func Start(exporters []config.Exporter) {
+	for i, c := range exporters {
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%s-%d", c.Type, i)
+		}
+
+		sink, err := NewSink(c)
+		if err != nil {
+			logger.Error("exporter", "%s: %s", c.Name, err.Error())
+			continue
+		}
+
+		e := New(c, sink)
+		go e.Run()
+
+		monitor.AddResultHandler(e.HandleResult)
+
+		logger.Green("exporter", "Exporting to %s at %s", c.Type, c.Address)
+	}
+}
+
+// expand fills in the placeholders of a naming template.
+func expand(template string, values map[string]string) string {
+	for key, value := range values {
+		template = strings.Replace(template, "{"+key+"}", value, -1)
+	}
+
+	return template
+}
+
+// Points converts a finished check to points according to the exporter
+// template.
+func (e *Exporter) Points(mon *monitor.Monitor, host *monitor.Host, result plugins.Result) []Point {
+	tags := map[string]string{
+		"host":    host.Name,
+		"agent":   mon.Agent.AgentId,
+		"monitor": mon.Id.Hex(),
+	}
+
+	if mon.Name != "" {
+		tags["name"] = mon.Name
+	}
+
+	for key, value := range mon.Labels {
+		if reservedTags[key] {
+			key = "label_" + key
+		}
+		tags[key] = value
+	}
+
+	values := map[string]string{
+		"host":    sanitize(host.Name),
+		"agent":   sanitize(mon.Agent.AgentId),
+		"monitor": mon.Id.Hex(),
+		"name":    sanitize(mon.Name),
+	}
+
+	// An empty segment would make series of unnamed monitors collide.
+	if values["name"] == "" {
+		values["name"] = mon.Id.Hex()
+	}
+
+	fields := map[string]float64{
+		"status":           float64(result.Status),
+		"duration_seconds": result.Duration.Seconds(),
+	}
+
+	units := map[string]plugins.Unit{
+		"duration_seconds": plugins.Seconds,
+	}
+
+	if result.Measurements != nil {
+		for key, value := range *result.Measurements {
+			// NaN and infinity can't be represented by the sinks.
+			if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+				continue
+			}
+
+			key = fieldKey(key, value.Unit)
+			fields[key] = value.Value
+			units[key] = value.Unit
+		}
+	}
+
+	t := mon.LastCheck
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	if !e.perKey {
+		return []Point{{Name: expand(e.template, values), Tags: tags, Fields: fields, Time: t}}
+	}
+
+	points := make([]Point, 0, len(fields))
+	for key, value := range fields {
+		values["key"] = sanitize(key)
+
+		// With a point per key, the unit of each value can be tagged.
+		pointTags := tags
+		if units[key] != "" {
+			pointTags = make(map[string]string, len(tags)+1)
+			for k, v := range tags {
+				pointTags[k] = v
+			}
+			pointTags["unit"] = string(units[key])
+		}
+
+		points = append(points, Point{
+			Name:   expand(e.template, values),
+			Tags:   pointTags,
+			Fields: map[string]float64{"value": value},
+			Time:   t,
+		})
+	}
+
+	return points
+}
+
+// fieldKey returns the name of the field for a measurement. Durations
+// were exported as nanoseconds before measurements had units, so values
+// in seconds get a name of their own instead of changing the unit of an
+// existing series.
+func fieldKey(key string, unit plugins.Unit) string {
+	if unit == plugins.Seconds && !strings.HasSuffix(key, "_seconds") {
+		return key + "_seconds"
+	}
+
+	return key
+}
+
+// HandleResult queues the points for a result. Points are dropped if the
+// queue is full, as the scheduler must never wait for an exporter.
+func (e *Exporter) HandleResult(mon *monitor.Monitor, host *monitor.Host, result plugins.Result) {
+	for _, p := range e.Points(mon, host, result) {
+		select {
+		case e.queue <- p:
+		default:
+			logger.Red("exporter", "%s: Queue full, dropping point %s", e.name, p.Name)
+		}
+	}
+}
+
+// flush writes points to the sink. Points buffered on disk are written
+// first to keep them in order. If the sink fails, points are added to the
+// disk buffer unless the sink rejected them.
+func (e *Exporter) flush(points []Point) {
+	if !e.buffer.Empty() {
+		err := e.buffer.Replay(e.batchSize, e.write)
+		if err != nil {
+			logger.Red("exporter", "%s: %s", e.name, err.Error())
+
+			err = e.buffer.Append(points)
+			if err != nil {
+				logger.Error("exporter", "%s: Error buffering points: %s", e.name, err.Error())
+			}
+			return
+		}
+
+		logger.Green("exporter", "%s: Disk buffer flushed", e.name)
+	}
+
+	if len(points) == 0 {
+		return
+	}
+
+	err := e.write(points)
+	if err != nil {
+		logger.Red("exporter", "%s: %s, buffering %d points on disk", e.name, err.Error(), len(points))
+
+		err = e.buffer.Append(points)
+		if err != nil {
+			logger.Error("exporter", "%s: Error buffering points: %s", e.name, err.Error())
+		}
+	}
+}
+
+// write writes points to the sink. Rejected points are logged and
+// dropped, so only errors worth retrying are returned.
+func (e *Exporter) write(points []Point) error {
+	err := e.sink.Write(points)
+	if isPermanent(err) {
+		logger.Error("exporter", "%s: %s, dropping %d points", e.name, err.Error(), len(points))
+		return nil
+	}
+
+	return err
+}
+
+// Run batches points from the queue until Stop is called.
+func (e *Exporter) Run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]Point, 0, e.batchSize)
+
+	for {
+		select {
+		case p, ok := <-e.queue:
+			if !ok {
+				e.flush(batch)
+				e.sink.Close()
+				return
+			}
+
+			batch = append(batch, p)
+			if len(batch) >= e.batchSize {
+				e.flush(batch)
+				batch = make([]Point, 0, e.batchSize)
+			}
+		case <-ticker.C:
+			e.flush(batch)
+			batch = make([]Point, 0, e.batchSize)
+		}
+	}
+}
+
+// Stop flushes queued points and closes the sink. HandleResult must not be
+// called after Stop.
+func (e *Exporter) Stop() {
+	close(e.queue)
+}
+
+// sanitize makes s usable as a path component in Graphite and as part of
+// an InfluxDB measurement name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,470 @@
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// influxServer records the lines written to it and fails requests
+	// with status while status is not zero.
+	influxServer struct {
+		sync.Mutex
+		*httptest.Server
+		status   int
+		requests [][]string
+	}
+)
+
+var (
+	testTime = time.Unix(1500000000, 0)
+	errTest  = errors.New("test")
+)
+
+func newInfluxServer() *influxServer {
+	s := &influxServer{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		defer s.Unlock()
+
+		if r.URL.Path != "/write" || r.URL.Query().Get("db") != "alerto" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		s.requests = append(s.requests, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"))
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return s
+}
+
+func (s *influxServer) fail(status int) {
+	s.Lock()
+	s.status = status
+	s.Unlock()
+}
+
+func (s *influxServer) lines() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	var lines []string
+	for _, r := range s.requests {
+		lines = append(lines, r...)
+	}
+
+	return lines
+}
+
+func newTestExporter(t *testing.T, c config.Exporter, sink Sink) *Exporter {
+	c.Name = "test"
+	c.BufferDirectory = t.TempDir()
+
+	return New(c, sink)
+}
+
+func testPoint(name string, value float64) Point {
+	return Point{
+		Name:   name,
+		Tags:   map[string]string{"host": "web1"},
+		Fields: map[string]float64{"value": value},
+		Time:   testTime,
+	}
+}
+
+func testMonitor() (*monitor.Monitor, *monitor.Host, plugins.Result) {
+	mon := &monitor.Monitor{
+		Id:        bson.ObjectIdHex("5a0c4cf3e4b0d3a1f0e1c2b3"),
+		Name:      "web check",
+		Agent:     plugins.Job{AgentId: "http"},
+		LastCheck: testTime,
+		Labels:    monitor.Labels{"env": "prod", "host": "spoofed"},
+	}
+
+	host := &monitor.Host{Name: "web1"}
+
+	measurements := plugins.NewMeasurementCollection()
+	measurements.Add("size", plugins.Measurement{Value: 1024, Unit: plugins.Bytes})
+	measurements.Add("time", plugins.Measurement{Value: 0.25, Unit: plugins.Seconds})
+	measurements.Add("broken", plugins.Measurement{Value: math.NaN()})
+	measurements.Add("infinite", plugins.Measurement{Value: math.Inf(1)})
+
+	result := plugins.Result{
+		Status:       plugins.Ok,
+		Duration:     500 * time.Millisecond,
+		Measurements: measurements,
+	}
+
+	return mon, host, result
+}
+
+func TestPoints(t *testing.T) {
+	e := newTestExporter(t, config.Exporter{Type: "influxdb-http"}, nil)
+
+	points := e.Points(testMonitor())
+	if len(points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(points))
+	}
+
+	p := points[0]
+	if p.Name != "alerto_http" {
+		t.Errorf("Wrong name '%s'", p.Name)
+	}
+
+	if p.Tags["host"] != "web1" || p.Tags["label_host"] != "spoofed" || p.Tags["env"] != "prod" {
+		t.Errorf("Wrong tags %v", p.Tags)
+	}
+
+	if len(p.Fields) != 4 || p.Fields["size"] != 1024 || p.Fields["duration_seconds"] != 0.5 || p.Fields["time_seconds"] != 0.25 {
+		t.Errorf("Wrong fields %v", p.Fields)
+	}
+
+	if !p.Time.Equal(testTime) {
+		t.Errorf("Wrong time %s", p.Time)
+	}
+}
+
+func TestPointsPerKey(t *testing.T) {
+	e := newTestExporter(t, config.Exporter{Type: "graphite"}, nil)
+
+	names := map[string]string{}
+	for _, p := range e.Points(testMonitor()) {
+		names[p.Name] = p.Tags["unit"]
+	}
+
+	expected := map[string]string{
+		"alerto.web1.http.web_check.status":           "",
+		"alerto.web1.http.web_check.duration_seconds": "s",
+		"alerto.web1.http.web_check.time_seconds":     "s",
+		"alerto.web1.http.web_check.size":             "bytes",
+	}
+
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+
+	for name, unit := range expected {
+		u, found := names[name]
+		if !found || u != unit {
+			t.Errorf("Expected %s with unit '%s', got %v", name, unit, names)
+		}
+	}
+
+	// Unnamed monitors are told apart by id.
+	mon, host, result := testMonitor()
+	mon.Name = ""
+
+	points := e.Points(mon, host, result)
+	if len(points) == 0 || !strings.HasPrefix(points[0].Name, "alerto.web1.http.5a0c4cf3e4b0d3a1f0e1c2b3.") {
+		t.Errorf("Expected the monitor id as name, got %v", points)
+	}
+
+	// Without {key}, Graphite appends field names itself.
+	e = newTestExporter(t, config.Exporter{Type: "graphite", Template: "alerto.{host}"}, nil)
+
+	points = e.Points(testMonitor())
+	if len(points) != 1 || len(points[0].Fields) != 4 {
+		t.Errorf("Expected a single point with all fields, got %v", points)
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	cases := []struct {
+		point    Point
+		expected string
+	}{
+		{
+			Point{
+				Name:   "cpu load",
+				Tags:   map[string]string{"host": "web,1", "empty": "", "a b": "c=d"},
+				Fields: map[string]float64{"value": 0.5, "count": 3},
+				Time:   testTime,
+			},
+			`cpu\ load,a\ b=c\=d,host=web\,1 count=3,value=0.5 1500000000000000000` + "\n",
+		},
+		{
+			Point{
+				Name:   "nan",
+				Fields: map[string]float64{"value": math.NaN(), "ok": 1},
+				Time:   testTime,
+			},
+			"nan ok=1 1500000000000000000\n",
+		},
+		{
+			Point{
+				Name:   "inf",
+				Fields: map[string]float64{"value": math.Inf(-1)},
+				Time:   testTime,
+			},
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		var b strings.Builder
+
+		err := writeLine(&b, c.point)
+		if err != nil {
+			t.Errorf("writeLine() failed: %s", err.Error())
+		}
+
+		if b.String() != c.expected {
+			t.Errorf("Expected %q, got %q", c.expected, b.String())
+		}
+	}
+}
+
+func TestInfluxHTTPBatching(t *testing.T) {
+	s := newInfluxServer()
+	defer s.Close()
+
+	c := config.Exporter{Type: "influxdb-http", BatchSize: 2, FlushInterval: config.Duration{Duration: time.Hour}}
+	e := newTestExporter(t, c, NewInfluxHTTP(s.URL, "alerto", "", ""))
+
+	done := make(chan struct{})
+	go func() {
+		e.Run()
+		close(done)
+	}()
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		e.queue <- testPoint(name, 1)
+	}
+	e.Stop()
+	<-done
+
+	if len(s.requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d: %v", len(s.requests), s.requests)
+	}
+
+	for i, size := range []int{2, 2, 1} {
+		if len(s.requests[i]) != size {
+			t.Errorf("Expected %d lines in request %d, got %v", size, i, s.requests[i])
+		}
+	}
+
+	if s.requests[0][0] != "a,host=web1 value=1 1500000000000000000" {
+		t.Errorf("Wrong line '%s'", s.requests[0][0])
+	}
+}
+
+func TestBufferReplay(t *testing.T) {
+	s := newInfluxServer()
+	defer s.Close()
+
+	e := newTestExporter(t, config.Exporter{Type: "influxdb-http", BatchSize: 2}, NewInfluxHTTP(s.URL, "alerto", "", ""))
+
+	s.fail(http.StatusServiceUnavailable)
+	e.flush([]Point{testPoint("a", 1), testPoint("b", 2), testPoint("c", 3)})
+	e.flush([]Point{testPoint("d", 4)})
+
+	if e.buffer.Empty() {
+		t.Fatalf("Points were not buffered")
+	}
+
+	if len(s.lines()) != 0 {
+		t.Fatalf("Unexpected lines %v", s.lines())
+	}
+
+	s.fail(0)
+	e.flush([]Point{testPoint("e", 5)})
+
+	if !e.buffer.Empty() {
+		t.Errorf("Buffer not emptied")
+	}
+
+	lines := s.lines()
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 lines, got %v", lines)
+	}
+
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		if !strings.HasPrefix(lines[i], name+",") {
+			t.Errorf("Expected line %d to be %s, got '%s'", i, name, lines[i])
+		}
+	}
+}
+
+func TestBufferReplayPartial(t *testing.T) {
+	var calls int
+
+	// The second batch fails once. The first must not be written again.
+	var written []string
+	write := func(points []Point) error {
+		calls++
+		if calls == 2 {
+			return errTest
+		}
+
+		for _, p := range points {
+			written = append(written, p.Name)
+		}
+
+		return nil
+	}
+
+	e := newTestExporter(t, config.Exporter{Type: "influxdb-http"}, nil)
+	e.buffer.Append([]Point{testPoint("a", 1), testPoint("b", 2), testPoint("c", 3), testPoint("d", 4), testPoint("e", 5)})
+
+	err := e.buffer.Replay(2, write)
+	if err != errTest {
+		t.Fatalf("Expected error, got %v", err)
+	}
+
+	err = e.buffer.Replay(2, write)
+	if err != nil {
+		t.Fatalf("Replay() failed: %s", err.Error())
+	}
+
+	if strings.Join(written, "") != "abcde" {
+		t.Errorf("Wrong points written: %v", written)
+	}
+
+	if !e.buffer.Empty() {
+		t.Errorf("Buffer not emptied")
+	}
+}
+
+func TestBufferReplayCorrupt(t *testing.T) {
+	e := newTestExporter(t, config.Exporter{Type: "influxdb-http"}, nil)
+	e.buffer.Append([]Point{testPoint("a", 1)})
+
+	// A line cut short by a crash, followed by points buffered later.
+	f, err := os.OpenFile(e.buffer.filename, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %s", err.Error())
+	}
+	f.WriteString(`{"name": "b", "fie` + "\n")
+	f.Close()
+
+	e.buffer.Append([]Point{testPoint("c", 3), testPoint("d", 4)})
+
+	var written []string
+	err = e.buffer.Replay(2, func(points []Point) error {
+		for _, p := range points {
+			written = append(written, p.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() failed: %s", err.Error())
+	}
+
+	if strings.Join(written, "") != "acd" {
+		t.Errorf("Wrong points written: %v", written)
+	}
+}
+
+func TestRejectedPointsDropped(t *testing.T) {
+	s := newInfluxServer()
+	defer s.Close()
+
+	e := newTestExporter(t, config.Exporter{Type: "influxdb-http"}, NewInfluxHTTP(s.URL, "alerto", "", ""))
+
+	s.fail(http.StatusBadRequest)
+	e.flush([]Point{testPoint("a", 1)})
+
+	if !e.buffer.Empty() {
+		t.Errorf("Rejected points were buffered")
+	}
+
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError} {
+		s.fail(status)
+		e.flush([]Point{testPoint("b", 2)})
+
+		if e.buffer.Empty() {
+			t.Errorf("Points failing with %d were not buffered", status)
+		}
+
+		s.fail(0)
+		e.flush(nil)
+	}
+
+	if len(s.lines()) != 2 {
+		t.Errorf("Expected 2 lines, got %v", s.lines())
+	}
+}
+
+func TestGraphite(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+	defer l.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	g := NewGraphite(l.Addr().String())
+	defer g.Close()
+
+	points := []Point{
+		testPoint("alerto.web1.load", 0.25),
+		{Name: "alerto.web1.http", Fields: map[string]float64{"status": 1}, Time: testTime},
+		testPoint("alerto.web1.nan", math.NaN()),
+	}
+
+	err = g.Write(points)
+	if err != nil {
+		t.Fatalf("Write() failed: %s", err.Error())
+	}
+
+	err = g.Write([]Point{testPoint("alerto.web1.second", 2)})
+	if err != nil {
+		t.Fatalf("Write() failed: %s", err.Error())
+	}
+
+	expected := []string{
+		"alerto.web1.load 0.25 1500000000",
+		"alerto.web1.http.status 1 1500000000",
+		"alerto.web1.second 2 1500000000",
+	}
+
+	for _, e := range expected {
+		select {
+		case line := <-lines:
+			if line != e {
+				t.Errorf("Expected '%s', got '%s'", e, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for '%s'", e)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+type (
+	// Graphite writes the plaintext protocol over TCP. The connection is
+	// kept open between writes and reestablished on errors.
+	Graphite struct {
+		address string
+		conn    net.Conn
+	}
+)
+
+const (
+	graphiteTimeout = 10 * time.Second
+)
+
+func NewGraphite(address string) *Graphite {
+	return &Graphite{
+		address: address,
+	}
+}
+
+func (g *Graphite) Write(points []Point) error {
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.address, graphiteTimeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+
+	w := bufio.NewWriter(g.conn)
+	for _, p := range points {
+		for field, value := range p.Fields {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			path := p.Name
+			if field != "value" {
+				path += "." + sanitize(field)
+			}
+
+			fmt.Fprintf(w, "%s %s %d\n", path, strconv.FormatFloat(value, 'g', -1, 64), p.Time.Unix())
+		}
+	}
+
+	err := w.Flush()
+	if err != nil {
+		g.Close()
+	}
+
+	return err
+}
+
+func (g *Graphite) Close() error {
+	if g.conn == nil {
+		return nil
+	}
+
+	err := g.conn.Close()
+	g.conn = nil
+
+	return err
+}
@@ -0,0 +1,187 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	InfluxHTTP struct {
+		url      string
+		username string
+		password string
+		client   *http.Client
+	}
+
+	InfluxUDP struct {
+		conn net.Conn
+	}
+)
+
+const (
+	// Keep UDP datagrams below a common MTU.
+	maxDatagramSize = 1400
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// writeLine writes p in the InfluxDB line protocol. Fields with values
+// not representable in the protocol are skipped, and nothing is written
+// if no fields are left.
+func writeLine(w io.Writer, p Point) error {
+	var line bytes.Buffer
+
+	line.WriteString(measurementEscaper.Replace(p.Name))
+
+	keys := make([]string, 0, len(p.Tags))
+	for key, value := range p.Tags {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&line, ",%s=%s", tagEscaper.Replace(key), tagEscaper.Replace(p.Tags[key]))
+	}
+
+	keys = keys[:0]
+	for key, value := range p.Fields {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	for i, key := range keys {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&line, "%s%s=%s", sep, tagEscaper.Replace(key), strconv.FormatFloat(p.Fields[key], 'g', -1, 64))
+	}
+
+	fmt.Fprintf(&line, " %d\n", p.Time.UnixNano())
+
+	_, err := w.Write(line.Bytes())
+
+	return err
+}
+
+func NewInfluxHTTP(address string, database string, username string, password string) *InfluxHTTP {
+	u, err := url.Parse(address)
+	if err == nil {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+		q := u.Query()
+		q.Set("db", database)
+		u.RawQuery = q.Encode()
+		address = u.String()
+	}
+
+	return &InfluxHTTP{
+		url:      address,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (i *InfluxHTTP) Write(points []Point) error {
+	var body bytes.Buffer
+	for _, p := range points {
+		writeLine(&body, p)
+	}
+
+	req, err := http.NewRequest("POST", i.url, &body)
+	if err != nil {
+		return err
+	}
+
+	if i.username != "" {
+		req.SetBasicAuth(i.username, i.password)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		err = fmt.Errorf("influxdb returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+
+		// Client errors mean the points themselves were rejected, except
+		// for timeouts and rate limiting.
+		switch {
+		case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		case resp.StatusCode/100 == 4:
+			err = permanentError{err}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (i *InfluxHTTP) Close() error {
+	return nil
+}
+
+func NewInfluxUDP(address string) (*InfluxUDP, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InfluxUDP{conn: conn}, nil
+}
+
+// Write sends points in as few datagrams as possible.
+func (i *InfluxUDP) Write(points []Point) error {
+	var datagram, line bytes.Buffer
+
+	for _, p := range points {
+		line.Reset()
+		writeLine(&line, p)
+
+		if datagram.Len() > 0 && datagram.Len()+line.Len() > maxDatagramSize {
+			_, err := i.conn.Write(datagram.Bytes())
+			if err != nil {
+				return err
+			}
+			datagram.Reset()
+		}
+
+		datagram.Write(line.Bytes())
+	}
+
+	if datagram.Len() > 0 {
+		_, err := i.conn.Write(datagram.Bytes())
+		return err
+	}
+
+	return nil
+}
+
+func (i *InfluxUDP) Close() error {
+	return i.conn.Close()
+}
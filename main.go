@@ -6,16 +6,24 @@ import (
 	"time"
 
 	"github.com/abrander/alerto/api"
-	_ "github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/exporter"
 	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/notify"
+	_ "github.com/abrander/alerto/plugins/cpu"
 	_ "github.com/abrander/alerto/plugins/dns"
+	"github.com/abrander/alerto/plugins/external"
 	_ "github.com/abrander/alerto/plugins/http"
 	_ "github.com/abrander/alerto/plugins/icmpping"
 	_ "github.com/abrander/alerto/plugins/load"
 	_ "github.com/abrander/alerto/plugins/localtransport"
+	_ "github.com/abrander/alerto/plugins/nagios"
+	_ "github.com/abrander/alerto/plugins/netdev"
 	_ "github.com/abrander/alerto/plugins/noop"
 	_ "github.com/abrander/alerto/plugins/pidof"
+	_ "github.com/abrander/alerto/plugins/script"
 	_ "github.com/abrander/alerto/plugins/ssh"
+	"github.com/abrander/alerto/report"
 )
 
 func init() {
@@ -25,6 +33,11 @@ func init() {
 func main() {
 	wg := sync.WaitGroup{}
 
+	external.Load(config.Current.Plugins)
+	notify.Setup(config.Current.Notifiers)
+	exporter.Start(config.Current.Exporters)
+	report.Start(config.Current.Reports)
+
 	wg.Add(1)
 	go api.Run(wg)
 
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// Labels are the labels of a single sample.
+	Labels map[string]string
+
+	Sample struct {
+		Labels Labels
+		Value  float64
+	}
+
+	// Family is a group of samples sharing name, help text and type, as
+	// in the Prometheus text format.
+	Family struct {
+		Name    string
+		Help    string
+		Type    string
+		Samples []Sample
+	}
+
+	// Collector provides families when metrics are gathered.
+	Collector interface {
+		Collect() []Family
+	}
+
+	// CollectorFunc adapts a function to the Collector interface.
+	CollectorFunc func() []Family
+
+	registry struct {
+		sync.RWMutex
+		collectors []Collector
+	}
+)
+
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+var (
+	defaultRegistry registry
+
+	invalidNameChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+)
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Register adds a collector to be used by Gather.
+func Register(c Collector) {
+	defaultRegistry.Lock()
+	defaultRegistry.collectors = append(defaultRegistry.collectors, c)
+	defaultRegistry.Unlock()
+}
+
+// Gather collects all families from registered collectors, sorted by name.
+func Gather() []Family {
+	defaultRegistry.RLock()
+	collectors := defaultRegistry.collectors
+	defaultRegistry.RUnlock()
+
+	families := []Family{}
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	return families
+}
+
+// SanitizeName turns s into a valid metric or label name.
+func SanitizeName(s string) string {
+	s = invalidNameChars.ReplaceAllString(s, "_")
+
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+
+	return s
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = SanitizeName(k) + `="` + labelValueEscaper.Replace(labels[k]) + `"`
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Write writes families in the Prometheus text exposition format.
+// Families with the same name are merged.
+func Write(w io.Writer, families []Family) error {
+	written := make(map[string]bool)
+
+	for _, f := range families {
+		if !written[f.Name] {
+			if f.Help != "" {
+				_, err := fmt.Fprintf(w, "# HELP %s %s\n", f.Name, strings.Replace(f.Help, "\n", " ", -1))
+				if err != nil {
+					return err
+				}
+			}
+
+			if f.Type != "" {
+				_, err := fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+				if err != nil {
+					return err
+				}
+			}
+
+			written[f.Name] = true
+		}
+
+		for _, s := range f.Samples {
+			_, err := fmt.Fprintf(w, "%s%s %s\n", f.Name, formatLabels(s.Labels), formatValue(s.Value))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
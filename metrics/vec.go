@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+type (
+	// Vec is a counter or gauge partitioned by label values.
+	Vec struct {
+		sync.Mutex
+		name       string
+		help       string
+		typ        string
+		labelNames []string
+		values     map[string]*entry
+	}
+
+	entry struct {
+		labels Labels
+		value  float64
+	}
+)
+
+func newVec(typ string, name string, help string, labelNames []string) *Vec {
+	v := &Vec{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		values:     make(map[string]*entry),
+	}
+
+	Register(v)
+
+	return v
+}
+
+// NewCounter creates and registers a counter.
+func NewCounter(name string, help string, labelNames ...string) *Vec {
+	return newVec(Counter, name, help, labelNames)
+}
+
+// NewGauge creates and registers a gauge.
+func NewGauge(name string, help string, labelNames ...string) *Vec {
+	return newVec(Gauge, name, help, labelNames)
+}
+
+func (v *Vec) get(labelValues []string) *entry {
+	key := strings.Join(labelValues, "\xff")
+
+	e, found := v.values[key]
+	if !found {
+		e = &entry{labels: Labels{}}
+		for i, name := range v.labelNames {
+			if i < len(labelValues) {
+				e.labels[name] = labelValues[i]
+			}
+		}
+		v.values[key] = e
+	}
+
+	return e
+}
+
+// Add adds delta to the value for labelValues, given in the order of the
+// label names.
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.Lock()
+	v.get(labelValues).value += delta
+	v.Unlock()
+}
+
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Set sets the value for labelValues. It should only be used on gauges.
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.Lock()
+	v.get(labelValues).value = value
+	v.Unlock()
+}
+
+func (v *Vec) Collect() []Family {
+	v.Lock()
+	defer v.Unlock()
+
+	f := Family{
+		Name:    v.name,
+		Help:    v.help,
+		Type:    v.typ,
+		Samples: make([]Sample, 0, len(v.values)),
+	}
+
+	for _, e := range v.values {
+		f.Samples = append(f.Samples, Sample{Labels: e.labels, Value: e.value})
+	}
+
+	return []Family{f}
+}
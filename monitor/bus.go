@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// SlowPolicy decides what happens to a subscriber whose buffer is full.
+	SlowPolicy int
+
+	// Subscriber receives changes on C. C is closed if the subscriber is
+	// disconnected for being too slow.
+	Subscriber struct {
+		C       chan Change
+		policy  SlowPolicy
+		dropped uint64
+	}
+
+	bus struct {
+		sync.Mutex
+		seq         uint64
+		history     []Change
+		subscribers map[*Subscriber]bool
+	}
+)
+
+const (
+	// Drop discards changes that do not fit in the buffer.
+	Drop SlowPolicy = iota
+
+	// Disconnect unsubscribes the subscriber and closes its channel.
+	Disconnect
+)
+
+const (
+	SubscriberBufferSize = 100
+	HistorySize          = 1000
+)
+
+var (
+	ErrorSequenceTooOld error = errors.New("Changes since the requested sequence number are no longer available")
+
+	// Sequence numbers start at the boot time in microseconds, so numbers
+	// handed out before a restart are always older than the history of
+	// this process. Microseconds keep them exact as JavaScript numbers.
+	changes = bus{
+		seq:         uint64(time.Now().UnixMicro()),
+		subscribers: make(map[*Subscriber]bool),
+	}
+)
+
+// broadcast assigns the next sequence number to a change and hands it to
+// all subscribers without blocking.
+func broadcast(typ string, payload interface{}) {
+	changes.Lock()
+	defer changes.Unlock()
+
+	changes.seq++
+	change := Change{
+		Seq:     changes.seq,
+		Type:    typ,
+		Payload: payload,
+	}
+
+	changes.history = append(changes.history, change)
+	if len(changes.history) > HistorySize {
+		changes.history = changes.history[len(changes.history)-HistorySize:]
+	}
+
+	for s := range changes.subscribers {
+		select {
+		case s.C <- change:
+		default:
+			s.dropped++
+
+			if s.policy == Disconnect {
+				logger.Yellow("monitor", "Disconnecting slow subscriber")
+				delete(changes.subscribers, s)
+				close(s.C)
+			}
+		}
+	}
+}
+
+// Subscribe returns a new subscriber. If since is non-zero, changes with
+// a sequence number above since are queued first. If some of them are no
+// longer available, or since is from before a restart, the subscriber is
+// still returned along with ErrorSequenceTooOld, and the caller should
+// reload everything.
+func Subscribe(since uint64, policy SlowPolicy) (*Subscriber, error) {
+	changes.Lock()
+	defer changes.Unlock()
+
+	var replay []Change
+	var err error
+
+	if since > changes.seq {
+		// Sequence numbers never go backwards unless the clock did
+		// across a restart.
+		err = ErrorSequenceTooOld
+	} else if since > 0 && since < changes.seq {
+		if len(changes.history) == 0 || changes.history[0].Seq > since+1 {
+			err = ErrorSequenceTooOld
+		}
+
+		for _, change := range changes.history {
+			if change.Seq > since {
+				replay = append(replay, change)
+			}
+		}
+	}
+
+	s := &Subscriber{
+		C:      make(chan Change, SubscriberBufferSize+len(replay)),
+		policy: policy,
+	}
+
+	for _, change := range replay {
+		s.C <- change
+	}
+
+	changes.subscribers[s] = true
+
+	return s, err
+}
+
+// Unsubscribe stops delivery to s. It is safe to call for subscribers
+// that have already been disconnected.
+func Unsubscribe(s *Subscriber) {
+	changes.Lock()
+	defer changes.Unlock()
+
+	_, found := changes.subscribers[s]
+	if found {
+		delete(changes.subscribers, s)
+		close(s.C)
+	}
+}
+
+// Dropped returns the number of changes s did not receive because its
+// buffer was full.
+func (s *Subscriber) Dropped() uint64 {
+	changes.Lock()
+	defer changes.Unlock()
+
+	return s.dropped
+}
+
+// Sequence returns the sequence number of the latest change.
+func Sequence() uint64 {
+	changes.Lock()
+	defer changes.Unlock()
+
+	return changes.seq
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"testing"
+)
+
+// restart resets the bus as if the process was restarted with seq as the
+// boot sequence number.
+func restart(seq uint64) {
+	changes.Lock()
+	defer changes.Unlock()
+
+	changes.seq = seq
+	changes.history = nil
+	changes.subscribers = make(map[*Subscriber]bool)
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	restart(1000)
+
+	for i := 0; i < 5; i++ {
+		broadcast("test", i)
+	}
+
+	s, err := Subscribe(1002, Drop)
+	if err != nil {
+		t.Fatalf("Subscribe() returned %s", err.Error())
+	}
+	defer Unsubscribe(s)
+
+	for _, seq := range []uint64{1003, 1004, 1005} {
+		change := <-s.C
+		if change.Seq != seq {
+			t.Errorf("Expected change %d, got %d", seq, change.Seq)
+		}
+	}
+
+	if len(s.C) != 0 {
+		t.Errorf("Expected no more changes, got %d", len(s.C))
+	}
+}
+
+func TestSubscribeTooOld(t *testing.T) {
+	restart(1000)
+
+	for i := 0; i < HistorySize+10; i++ {
+		broadcast("test", i)
+	}
+
+	s, err := Subscribe(1005, Drop)
+	defer Unsubscribe(s)
+
+	if err != ErrorSequenceTooOld {
+		t.Errorf("Expected %s, got %v", ErrorSequenceTooOld.Error(), err)
+	}
+}
+
+func TestSubscribeAfterRestart(t *testing.T) {
+	cases := []struct {
+		name    string
+		since   uint64
+		boot    uint64
+		changes int
+	}{
+		// The client has seen more changes than this process has made,
+		// which would happen if sequence numbers restarted from zero.
+		{"ahead", 5000, 1000, 3},
+
+		// The client saw changes from a previous boot.
+		{"previous boot", 1500, 2000, 5},
+	}
+
+	for _, c := range cases {
+		restart(c.boot)
+
+		for i := 0; i < c.changes; i++ {
+			broadcast("test", i)
+		}
+
+		s, err := Subscribe(c.since, Drop)
+		if err != ErrorSequenceTooOld {
+			t.Errorf("%s: Expected %s, got %v", c.name, ErrorSequenceTooOld.Error(), err)
+		}
+
+		Unsubscribe(s)
+	}
+}
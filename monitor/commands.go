@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// setFields updates only the given fields of a monitor and broadcasts the
+// result as a monchange.
+func setFields(id bson.ObjectId, fields bson.M) (Monitor, error) {
+	var mon Monitor
+
+	err := monitorCollection.UpdateId(id, bson.M{"$set": fields})
+	if err != nil {
+		return mon, err
+	}
+
+	err = monitorCollection.FindId(id).One(&mon)
+	if err != nil {
+		return mon, err
+	}
+
+	broadcast("monchange", mon)
+
+	return mon, nil
+}
+
+// RunNow schedules a monitor to be checked as soon as possible.
+func RunNow(id string) (Monitor, error) {
+	if !bson.IsObjectIdHex(id) {
+		return Monitor{}, ErrorInvalidId
+	}
+
+	return setFields(bson.ObjectIdHex(id), bson.M{"nextcheck": time.Now()})
+}
+
+// SetPaused pauses or resumes checking of a monitor.
+func SetPaused(id string, paused bool) (Monitor, error) {
+	if !bson.IsObjectIdHex(id) {
+		return Monitor{}, ErrorInvalidId
+	}
+
+	mon, err := setFields(bson.ObjectIdHex(id), bson.M{"paused": paused})
+	if err == nil && paused {
+		recordState(mon.Id, Unknown, time.Now())
+		closeOpenIncident(mon.Id, "PAUSED", "Monitor paused")
+	}
+
+	return mon, err
+}
+
+// Acknowledge marks a monitor as acknowledged by someone. The
+// acknowledgement is cleared by the scheduler when the monitor recovers.
+func Acknowledge(id string, by string, comment string) (Monitor, error) {
+	if !bson.IsObjectIdHex(id) {
+		return Monitor{}, ErrorInvalidId
+	}
+
+	ack := Ack{
+		By:      by,
+		Time:    time.Now(),
+		Comment: comment,
+	}
+
+	mon, err := setFields(bson.ObjectIdHex(id), bson.M{"ack": ack})
+	if err != nil {
+		return mon, err
+	}
+
+	incident, err := openIncident(mon.Id)
+	if err == nil {
+		addEntry(incident.Id, TimelineEntry{Time: ack.Time, Type: "ack", Actor: by, Text: comment}, nil)
+	}
+
+	return mon, nil
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"fmt"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	Inconsistency struct {
+		Collection string        `json:"collection"`
+		Id         bson.ObjectId `json:"id"`
+		Problem    string        `json:"problem"`
+	}
+)
+
+// CheckConsistency looks for monitors referencing hosts that no longer exist
+// and for documents referencing plugins that are not compiled in. The raw
+// documents are examined, as SetBSON would refuse to decode the latter.
+func CheckConsistency() []Inconsistency {
+	problems := []Inconsistency{}
+
+	var hosts []struct {
+		Id          bson.ObjectId `bson:"_id"`
+		TransportId string        `bson:"transportId"`
+	}
+
+	err := hostCollection.Find(bson.M{}).All(&hosts)
+	if err != nil {
+		logger.Red("monitor", "Error getting hosts from Mongo: %s", err.Error())
+		return problems
+	}
+
+	hostIds := make(map[bson.ObjectId]bool)
+	for _, host := range hosts {
+		hostIds[host.Id] = true
+
+		_, found := plugins.GetPlugin(host.TransportId)
+		if !found {
+			problems = append(problems, Inconsistency{
+				Collection: "hosts",
+				Id:         host.Id,
+				Problem:    fmt.Sprintf("unknown transportId '%s'", host.TransportId),
+			})
+		}
+	}
+
+	var monitors []struct {
+		Id     bson.ObjectId `bson:"_id"`
+		HostId bson.ObjectId `bson:"hostId"`
+		Agent  struct {
+			AgentId string `bson:"agentId"`
+		} `bson:"agent"`
+	}
+
+	err = monitorCollection.Find(bson.M{}).All(&monitors)
+	if err != nil {
+		logger.Red("monitor", "Error getting monitors from Mongo: %s", err.Error())
+		return problems
+	}
+
+	for _, mon := range monitors {
+		if !hostIds[mon.HostId] {
+			problems = append(problems, Inconsistency{
+				Collection: "monitors",
+				Id:         mon.Id,
+				Problem:    fmt.Sprintf("orphaned, host %s does not exist", mon.HostId.Hex()),
+			})
+		}
+
+		_, found := plugins.GetPlugin(mon.Agent.AgentId)
+		if !found {
+			problems = append(problems, Inconsistency{
+				Collection: "monitors",
+				Id:         mon.Id,
+				Problem:    fmt.Sprintf("unknown agentId '%s'", mon.Agent.AgentId),
+			})
+		}
+	}
+
+	for _, p := range problems {
+		logger.Error("monitor", "Inconsistency in %s %s: %s", p.Collection, p.Id.Hex(), p.Problem)
+	}
+
+	return problems
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"gopkg.in/mgo.v2/bson"
+)
+
+type (
+	// Filter selects monitors and the changes concerning them. Empty fields
+	// match everything.
+	Filter struct {
+		HostId     string   `json:"hostId"`
+		MonitorIds []string `json:"monitorIds"`
+		Labels     string   `json:"labels"`
+
+		selector Selector
+	}
+)
+
+// Compile validates the filter and must be called before matching.
+func (f *Filter) Compile() error {
+	if f.HostId != "" && !bson.IsObjectIdHex(f.HostId) {
+		return ErrorInvalidId
+	}
+
+	for _, id := range f.MonitorIds {
+		if !bson.IsObjectIdHex(id) {
+			return ErrorInvalidId
+		}
+	}
+
+	var err error
+	f.selector, err = ParseSelector(f.Labels)
+
+	return err
+}
+
+func (f *Filter) matchMonitorId(id string) bool {
+	if len(f.MonitorIds) == 0 {
+		return true
+	}
+
+	for _, i := range f.MonitorIds {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchMonitor returns true if mon is selected by the filter.
+func (f *Filter) MatchMonitor(mon *Monitor) bool {
+	if f.HostId != "" && mon.HostId.Hex() != f.HostId {
+		return false
+	}
+
+	return f.matchMonitorId(mon.Id.Hex()) && f.selector.Matches(mon.Labels)
+}
+
+// MatchChange returns true if change concerns something selected by the
+// filter. Changes other than to monitors and hosts are matched on the
+// monitor they concern. Deletions only carry an id, so they are matched as
+// well as possible.
+func (f *Filter) MatchChange(change Change) bool {
+	switch payload := change.Payload.(type) {
+	case Monitor:
+		return f.MatchMonitor(&payload)
+	case Host:
+		return f.HostId == "" || payload.Id.Hex() == f.HostId
+	case Incident:
+		if f.HostId != "" && payload.HostId.Hex() != f.HostId {
+			return false
+		}
+
+		return f.matchMonitorId(payload.MonitorId.Hex())
+	case StateChange:
+		return f.matchMonitorId(payload.MonitorId.Hex())
+	case Maintenance:
+		// A window without monitor ids may cover any monitor.
+		if len(payload.Filter.MonitorIds) == 0 {
+			return true
+		}
+
+		for _, id := range payload.Filter.MonitorIds {
+			if f.matchMonitorId(id) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch change.Type {
+	case "mondelete":
+		id, _ := change.Payload.(string)
+		return f.matchMonitorId(id)
+	case "hostdelete":
+		id, _ := change.Payload.(string)
+		return f.HostId == "" || id == f.HostId
+	}
+
+	return true
+}
+
+// Query returns a Mongo query equivalent to MatchMonitor.
+func (f *Filter) Query() bson.M {
+	query := f.selector.Query()
+
+	if f.HostId != "" {
+		query["hostId"] = bson.ObjectIdHex(f.HostId)
+	}
+
+	if len(f.MonitorIds) > 0 {
+		ids := make([]bson.ObjectId, len(f.MonitorIds))
+		for i, id := range f.MonitorIds {
+			ids[i] = bson.ObjectIdHex(id)
+		}
+
+		query["_id"] = bson.M{"$in": ids}
+	}
+
+	return query
+}
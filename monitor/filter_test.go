@@ -0,0 +1,47 @@
+package monitor
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMatchChange(t *testing.T) {
+	mon := bson.ObjectIdHex("5a0c4cf3e4b0d3a1f0e1c2b3")
+	other := bson.ObjectIdHex("5a0c4cf3e4b0d3a1f0e1c2b4")
+	host := bson.ObjectIdHex("5a0c4cf3e4b0d3a1f0e1c2c0")
+
+	f := Filter{HostId: host.Hex(), MonitorIds: []string{mon.Hex()}}
+	err := f.Compile()
+	if err != nil {
+		t.Fatalf("Compile() failed: %s", err.Error())
+	}
+
+	cases := []struct {
+		name     string
+		change   Change
+		expected bool
+	}{
+		{"monitor", Change{Type: "monchange", Payload: Monitor{Id: mon, HostId: host}}, true},
+		{"other monitor", Change{Type: "monchange", Payload: Monitor{Id: other, HostId: host}}, false},
+		{"monitor delete", Change{Type: "mondelete", Payload: mon.Hex()}, true},
+		{"other monitor delete", Change{Type: "mondelete", Payload: other.Hex()}, false},
+		{"incident", Change{Type: "incidentopen", Payload: Incident{MonitorId: mon, HostId: host}}, true},
+		{"other incident", Change{Type: "incidentchange", Payload: Incident{MonitorId: other, HostId: host}}, false},
+		{"incident on other host", Change{Type: "incidentclose", Payload: Incident{MonitorId: mon, HostId: other}}, false},
+		{"state change", Change{Type: "statechange", Payload: StateChange{MonitorId: mon}}, true},
+		{"other state change", Change{Type: "statechange", Payload: StateChange{MonitorId: other}}, false},
+		{"maintenance", Change{Type: "maintenanceadd", Payload: Maintenance{Filter: Filter{MonitorIds: []string{other.Hex(), mon.Hex()}}}}, true},
+		{"other maintenance", Change{Type: "maintenanceadd", Payload: Maintenance{Filter: Filter{MonitorIds: []string{other.Hex()}}}}, false},
+		{"maintenance by labels", Change{Type: "maintenanceadd", Payload: Maintenance{Filter: Filter{Labels: "env=prod"}}}, true},
+		{"maintenance delete", Change{Type: "maintenancedelete", Payload: bson.NewObjectId().Hex()}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if f.MatchChange(c.change) != c.expected {
+				t.Errorf("Expected %t for %+v", c.expected, c.change)
+			}
+		})
+	}
+}
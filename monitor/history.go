@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// ResultRecord is a result kept in the history.
+	ResultRecord struct {
+		Id        bson.ObjectId  `json:"id" bson:"_id"`
+		MonitorId bson.ObjectId  `json:"monitorId" bson:"monitorId"`
+		Time      time.Time      `json:"time"`
+		Result    plugins.Result `json:"result"`
+	}
+
+	// StateChange records a monitor changing state. From is Unknown for
+	// the first check of a monitor.
+	StateChange struct {
+		Id        bson.ObjectId  `json:"id" bson:"_id"`
+		MonitorId bson.ObjectId  `json:"monitorId" bson:"monitorId"`
+		Time      time.Time      `json:"time"`
+		From      plugins.Status `json:"from"`
+		To        plugins.Status `json:"to"`
+	}
+)
+
+const (
+	// Unknown is the state of monitors never checked or paused.
+	Unknown plugins.Status = -1
+
+	// ResultRetention is how long individual results are kept. State
+	// changes are kept forever.
+	ResultRetention = time.Hour * 24 * 92
+)
+
+var (
+	stateCacheLock sync.Mutex
+	stateCache     = make(map[bson.ObjectId]plugins.Status)
+)
+
+func recordResult(monitorId bson.ObjectId, r plugins.Result, t time.Time) {
+	record := ResultRecord{
+		Id:        bson.NewObjectId(),
+		MonitorId: monitorId,
+		Time:      t,
+		Result:    r,
+	}
+
+	err := resultCollection.Insert(record)
+	if err != nil {
+		logger.Red("monitor", "Error recording result: %s", err.Error())
+	}
+}
+
+// currentState returns the latest recorded state of a monitor.
+func currentState(monitorId bson.ObjectId) plugins.Status {
+	state, found := stateCache[monitorId]
+	if found {
+		return state
+	}
+
+	var latest StateChange
+	err := stateCollection.Find(bson.M{"monitorId": monitorId}).Sort("-time").One(&latest)
+	if err != nil {
+		return Unknown
+	}
+
+	return latest.To
+}
+
+// recordState records a state change if state differs from the latest
+// recorded state.
+func recordState(monitorId bson.ObjectId, state plugins.Status, t time.Time) {
+	stateCacheLock.Lock()
+	defer stateCacheLock.Unlock()
+
+	from := currentState(monitorId)
+	if from == state {
+		stateCache[monitorId] = state
+		return
+	}
+
+	change := StateChange{
+		Id:        bson.NewObjectId(),
+		MonitorId: monitorId,
+		Time:      t,
+		From:      from,
+		To:        state,
+	}
+
+	err := stateCollection.Insert(change)
+	if err != nil {
+		logger.Red("monitor", "Error recording state: %s", err.Error())
+		return
+	}
+
+	stateCache[monitorId] = state
+
+	broadcast("statechange", change)
+}
+
+// GetResults returns recorded results for a monitor between from and to,
+// newest first.
+func GetResults(monitorId string, from time.Time, to time.Time, limit int) ([]ResultRecord, error) {
+	results := []ResultRecord{}
+
+	if !bson.IsObjectIdHex(monitorId) {
+		return results, ErrorInvalidId
+	}
+
+	q := resultCollection.Find(bson.M{
+		"monitorId": bson.ObjectIdHex(monitorId),
+		"time":      bson.M{"$gte": from, "$lt": to},
+	}).Sort("-time")
+
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	err := q.All(&results)
+
+	return results, err
+}
+
+// GetStateChanges returns the state changes of a monitor between from and
+// to, oldest first. The latest change before from is included if it
+// exists, to tell the state at from.
+func GetStateChanges(monitorId bson.ObjectId, from time.Time, to time.Time) ([]StateChange, error) {
+	changes := []StateChange{}
+
+	var before StateChange
+	err := stateCollection.Find(bson.M{
+		"monitorId": monitorId,
+		"time":      bson.M{"$lt": from},
+	}).Sort("-time").One(&before)
+	if err == nil {
+		changes = append(changes, before)
+	} else if err != mgo.ErrNotFound {
+		return nil, err
+	}
+
+	var within []StateChange
+	err = stateCollection.Find(bson.M{
+		"monitorId": monitorId,
+		"time":      bson.M{"$gte": from, "$lt": to},
+	}).Sort("time").All(&within)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(changes, within...), nil
+}
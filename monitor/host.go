@@ -47,37 +47,47 @@ func GetHost(id string) (Host, error) {
 }
 
 func AddHost(host *Host) error {
-	host.Id = bson.NewObjectId()
-
-	change := Change{
-		Type:    "hostadd",
-		Payload: *host,
+	err := plugins.Validate(host.Transport)
+	if err != nil {
+		return err
 	}
 
-	channelLock.Lock()
-	for _, ch := range changes {
-		ch <- change
-	}
-	channelLock.Unlock()
+	host.Id = bson.NewObjectId()
+
+	broadcast("hostadd", *host)
 
 	return hostCollection.Insert(host)
 }
 
-func DeleteHost(id string) error {
+// DeleteHost removes a host. If monitors still reference the host, the
+// deletion is rejected with ErrorHostInUse unless cascade is true, in which
+// case the monitors are deleted as well.
+func DeleteHost(id string, cascade bool) error {
 	if !bson.IsObjectIdHex(id) {
 		return ErrorInvalidId
 	}
 
-	change := Change{
-		Type:    "hostdelete",
-		Payload: id,
+	var monitors []struct {
+		Id bson.ObjectId `bson:"_id"`
+	}
+
+	err := monitorCollection.Find(bson.M{"hostId": bson.ObjectIdHex(id)}).Select(bson.M{"_id": 1}).All(&monitors)
+	if err != nil {
+		return err
+	}
+
+	if len(monitors) > 0 && !cascade {
+		return ErrorHostInUse
 	}
 
-	channelLock.Lock()
-	for _, ch := range changes {
-		ch <- change
+	for _, mon := range monitors {
+		err = DeleteMonitor(mon.Id.Hex())
+		if err != nil {
+			return err
+		}
 	}
-	channelLock.Unlock()
+
+	broadcast("hostdelete", id)
 
 	return hostCollection.RemoveId(bson.ObjectIdHex(id))
 }
@@ -140,9 +150,29 @@ func (host *Host) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	plugins.ApplyDefaults(host.Transport)
+
 	return nil
 }
 
+// MarshalJSON encodes host with the secret parameters of the transport
+// redacted.
+func (host Host) MarshalJSON() ([]byte, error) {
+	type plain Host
+
+	return json.Marshal(plain(host.Redacted()))
+}
+
+// Redacted returns a copy of host with the secret parameters of the
+// transport redacted.
+func (host Host) Redacted() Host {
+	if host.Transport != nil {
+		host.Transport, _ = plugins.Redact(host.Transport).(plugins.Transport)
+	}
+
+	return host
+}
+
 func (host *Host) SetBSON(raw bson.Raw) error {
 	m := make(map[string]bson.Raw)
 
@@ -199,5 +229,7 @@ func (host *Host) SetBSON(raw bson.Raw) error {
 		return err
 	}
 
+	plugins.ApplyDefaults(host.Transport)
+
 	return nil
 }
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// secretTransport is a transport with a secret parameter.
+	secretTransport struct {
+		Address string `json:"address"`
+		Token   string `json:"token" secret:"true"`
+	}
+)
+
+var (
+	errorNotImplemented = errors.New("Not implemented")
+)
+
+func (t *secretTransport) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{Name: "secret"}
+}
+
+func (t *secretTransport) Exec(plugins.Command) (*plugins.Process, error) {
+	return nil, errorNotImplemented
+}
+
+func (t *secretTransport) Dial(network string, address string) (net.Conn, error) {
+	return nil, errorNotImplemented
+}
+
+func (t *secretTransport) ReadFile(path string) (io.Reader, error) {
+	return nil, errorNotImplemented
+}
+
+func TestHostSecrets(t *testing.T) {
+	transport := &secretTransport{Address: "example.com", Token: "hunter2"}
+	host := Host{Id: bson.NewObjectId(), Name: "example", TransportId: "secret-transport", Transport: transport}
+
+	data, err := json.Marshal(host)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err.Error())
+	}
+
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), plugins.RedactedSecret) {
+		t.Errorf("Secret not redacted in %s", data)
+	}
+
+	if transport.Token != "hunter2" {
+		t.Errorf("Marshal() changed the transport")
+	}
+
+	r := host.Redacted()
+	if r.Transport.(*secretTransport).Token != plugins.RedactedSecret {
+		t.Errorf("Secret not redacted in %+v", r.Transport)
+	}
+}
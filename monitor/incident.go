@@ -0,0 +1,255 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/notify"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Incident covers the time from a monitor entering a hard failure
+	// until it recovers.
+	Incident struct {
+		Id        bson.ObjectId   `json:"id" bson:"_id"`
+		MonitorId bson.ObjectId   `json:"monitorId" bson:"monitorId"`
+		HostId    bson.ObjectId   `json:"hostId" bson:"hostId"`
+		Title     string          `json:"title"`
+		Open      bool            `json:"open"`
+		Opened    time.Time       `json:"opened"`
+		Closed    time.Time       `json:"closed"`
+		Timeline  []TimelineEntry `json:"timeline"`
+	}
+
+	// TimelineEntry is a single event in the life of an incident. Type is
+	// one of "opened", "state", "notification", "ack", "note" and
+	// "closed".
+	TimelineEntry struct {
+		Time  time.Time `json:"time"`
+		Type  string    `json:"type"`
+		Actor string    `json:"actor,omitempty"`
+		Text  string    `json:"text"`
+	}
+)
+
+// hardFailure returns true if mon has failed enough times in a row to
+// open an incident.
+func (mon *Monitor) hardFailure() bool {
+	threshold := mon.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return mon.Failures >= threshold
+}
+
+func openIncident(monitorId bson.ObjectId) (Incident, error) {
+	var incident Incident
+
+	err := incidentCollection.Find(bson.M{"monitorId": monitorId, "open": true}).One(&incident)
+
+	return incident, err
+}
+
+// addEntry appends an entry to the timeline of an incident and broadcasts
+// the updated incident.
+func addEntry(id bson.ObjectId, entry TimelineEntry, set bson.M) (Incident, error) {
+	var incident Incident
+
+	update := bson.M{"$push": bson.M{"timeline": entry}}
+	if set != nil {
+		update["$set"] = set
+	}
+
+	change := mgo.Change{
+		Update:    update,
+		ReturnNew: true,
+	}
+
+	_, err := incidentCollection.FindId(id).Apply(change, &incident)
+	if err != nil {
+		return incident, err
+	}
+
+	broadcast("incidentchange", incident)
+
+	return incident, nil
+}
+
+// notifyIncident tells the configured notifiers about an incident and
+// records it in the timeline.
+func notifyIncident(incident Incident, subject string, text string) {
+	for _, name := range config.Current.Incidents.Notifiers {
+		n := notify.Notification{
+			Subject: subject,
+			Text:    text,
+			Data:    incident,
+		}
+
+		entry := TimelineEntry{
+			Time:  time.Now(),
+			Type:  "notification",
+			Actor: name,
+			Text:  subject,
+		}
+
+		err := notify.Send(name, n)
+		if err != nil {
+			logger.Red("monitor", "Error notifying %s: %s", name, err.Error())
+			entry.Text = fmt.Sprintf("%s: failed: %s", subject, err.Error())
+		}
+
+		_, err = addEntry(incident.Id, entry, nil)
+		if err != nil {
+			logger.Red("monitor", "Error updating incident: %s", err.Error())
+		}
+	}
+}
+
+func monitorTitle(mon *Monitor) string {
+	if mon.Name != "" {
+		return mon.Name
+	}
+
+	return mon.Agent.AgentId + " " + mon.Id.Hex()
+}
+
+// updateIncident opens, updates or closes the incident of a monitor after
+// a check.
+func updateIncident(mon *Monitor, r plugins.Result, t time.Time) {
+	incident, err := openIncident(mon.Id)
+	if err != nil && err != mgo.ErrNotFound {
+		logger.Red("monitor", "Error getting incident from Mongo: %s", err.Error())
+		return
+	}
+
+	found := err == nil
+
+	switch {
+	case !found && r.Status != plugins.Ok && mon.hardFailure():
+		incident = Incident{
+			Id:        bson.NewObjectId(),
+			MonitorId: mon.Id,
+			HostId:    mon.HostId,
+			Title:     monitorTitle(mon),
+			Open:      true,
+			Opened:    t,
+			Timeline: []TimelineEntry{
+				{Time: t, Type: "opened", Text: r.Text},
+			},
+		}
+
+		err = incidentCollection.Insert(incident)
+		if err != nil {
+			logger.Red("monitor", "Error opening incident: %s", err.Error())
+			return
+		}
+
+		broadcast("incidentopen", incident)
+		logger.Red("monitor", "%s: Opened incident %s", mon.Id.Hex(), incident.Id.Hex())
+
+		go notifyIncident(incident, "FAILED: "+incident.Title, r.Text)
+
+	case found && r.Status == plugins.Ok:
+		closeIncident(incident, t, "RECOVERED", r.Text)
+
+	case found:
+		// Only record state changes telling something new.
+		last := incident.Timeline[len(incident.Timeline)-1]
+		if last.Text != r.Text {
+			_, err = addEntry(incident.Id, TimelineEntry{Time: t, Type: "state", Text: r.Text}, nil)
+			if err != nil {
+				logger.Red("monitor", "Error updating incident: %s", err.Error())
+			}
+		}
+	}
+}
+
+// closeIncident closes an open incident and tells the notifiers, using
+// reason as the subject prefix.
+func closeIncident(incident Incident, t time.Time, reason string, text string) {
+	incident, err := addEntry(incident.Id, TimelineEntry{Time: t, Type: "closed", Text: text}, bson.M{"open": false, "closed": t})
+	if err != nil {
+		logger.Red("monitor", "Error closing incident: %s", err.Error())
+		return
+	}
+
+	broadcast("incidentclose", incident)
+	logger.Green("monitor", "%s: Closed incident %s", incident.MonitorId.Hex(), incident.Id.Hex())
+
+	go notifyIncident(incident, reason+": "+incident.Title, text)
+}
+
+// closeOpenIncident closes the open incident of a monitor, if any, when
+// the monitor stops being checked.
+func closeOpenIncident(monitorId bson.ObjectId, reason string, text string) {
+	incident, err := openIncident(monitorId)
+	if err == mgo.ErrNotFound {
+		return
+	} else if err != nil {
+		logger.Red("monitor", "Error getting incident from Mongo: %s", err.Error())
+		return
+	}
+
+	closeIncident(incident, time.Now(), reason, text)
+}
+
+// AddIncidentNote adds a free-text note from a responder to an incident.
+func AddIncidentNote(id string, actor string, text string) (Incident, error) {
+	if !bson.IsObjectIdHex(id) {
+		return Incident{}, ErrorInvalidId
+	}
+
+	return addEntry(bson.ObjectIdHex(id), TimelineEntry{
+		Time:  time.Now(),
+		Type:  "note",
+		Actor: actor,
+		Text:  text,
+	}, nil)
+}
+
+func GetIncident(id string) (Incident, error) {
+	var incident Incident
+
+	if !bson.IsObjectIdHex(id) {
+		return incident, ErrorInvalidId
+	}
+
+	err := incidentCollection.FindId(bson.ObjectIdHex(id)).One(&incident)
+
+	return incident, err
+}
+
+// GetIncidents returns incidents, newest first. If monitorId is not empty,
+// only incidents for that monitor are returned.
+func GetIncidents(openOnly bool, monitorId string, limit int) ([]Incident, error) {
+	incidents := []Incident{}
+	query := bson.M{}
+
+	if openOnly {
+		query["open"] = true
+	}
+
+	if monitorId != "" {
+		if !bson.IsObjectIdHex(monitorId) {
+			return incidents, ErrorInvalidId
+		}
+
+		query["monitorId"] = bson.ObjectIdHex(monitorId)
+	}
+
+	q := incidentCollection.Find(query).Sort("-opened")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	err := q.All(&incidents)
+
+	return incidents, err
+}
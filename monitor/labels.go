@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type (
+	// Labels are free-form key/value pairs attached to monitors by users.
+	Labels map[string]string
+
+	// Selector matches labels. It is parsed from a comma separated list of
+	// requirements like "env=prod,team!=ops,critical,!deprecated".
+	Selector []requirement
+
+	requirement struct {
+		key    string
+		value  string
+		op     string
+		negate bool
+	}
+)
+
+// ParseSelector parses a label selector. An empty string matches
+// everything.
+func ParseSelector(selector string) (Selector, error) {
+	s := Selector{}
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r := requirement{}
+
+		if i := strings.Index(part, "!="); i >= 0 {
+			r.key, r.value, r.op, r.negate = part[:i], part[i+2:], "=", true
+		} else if i := strings.Index(part, "="); i >= 0 {
+			r.key, r.value, r.op = part[:i], part[i+1:], "="
+		} else if strings.HasPrefix(part, "!") {
+			r.key, r.negate = part[1:], true
+		} else {
+			r.key = part
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+
+		if r.key == "" || strings.ContainsAny(r.key, ".$") {
+			return nil, fmt.Errorf("invalid label selector '%s'", part)
+		}
+
+		s = append(s, r)
+	}
+
+	return s, nil
+}
+
+// Matches returns true if labels satisfy every requirement of the selector.
+func (s Selector) Matches(labels Labels) bool {
+	for _, r := range s {
+		value, found := labels[r.key]
+
+		var match bool
+		if r.op == "=" {
+			match = found && value == r.value
+		} else {
+			match = found
+		}
+
+		if match == r.negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Query returns a Mongo query equivalent to Matches for documents storing
+// labels in the field named "labels".
+func (s Selector) Query() bson.M {
+	query := bson.M{}
+	and := []bson.M{}
+
+	for _, r := range s {
+		field := "labels." + r.key
+
+		switch {
+		case r.op == "=" && r.negate:
+			and = append(and, bson.M{field: bson.M{"$ne": r.value}})
+		case r.op == "=":
+			and = append(and, bson.M{field: r.value})
+		default:
+			and = append(and, bson.M{field: bson.M{"$exists": !r.negate}})
+		}
+	}
+
+	if len(and) > 0 {
+		query["$and"] = and
+	}
+
+	return query
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+
+	for i, r := range s {
+		switch {
+		case r.op == "=" && r.negate:
+			parts[i] = r.key + "!=" + r.value
+		case r.op == "=":
+			parts[i] = r.key + "=" + r.value
+		case r.negate:
+			parts[i] = "!" + r.key
+		default:
+			parts[i] = r.key
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,283 @@
+package monitor
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type (
+	// ListOptions controls paging, sorting and searching of lists. Filter
+	// must be compiled.
+	ListOptions struct {
+		Filter Filter
+		Search string
+
+		// Sort is the name of a sortable field, optionally prefixed by "-"
+		// for descending order.
+		Sort string
+
+		// Limit is the maximum number of documents to return. Zero means
+		// no limit.
+		Limit int
+
+		// Cursor is the value returned as next by a previous call with the
+		// same options.
+		Cursor string
+	}
+
+	cursor struct {
+		Value interface{}   `bson:"v"`
+		Id    bson.ObjectId `bson:"id"`
+	}
+)
+
+var (
+	ErrorInvalidCursor error = errors.New("Invalid cursor")
+	ErrorInvalidSort   error = errors.New("Invalid sort field")
+
+	// Sortable fields for monitors, mapped to their Mongo field.
+	monitorSortFields = map[string]string{
+		"name":      "name",
+		"agentId":   "agent.agentId",
+		"status":    "lastresult.status",
+		"lastCheck": "lastcheck",
+		"nextCheck": "nextcheck",
+		"duration":  "lastresult.duration",
+		"interval":  "interval",
+	}
+
+	hostSortFields = map[string]string{
+		"name":        "name",
+		"transportId": "transportId",
+	}
+)
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrorInvalidCursor
+	}
+
+	err = bson.Unmarshal(data, &c)
+	if err != nil || !c.Id.Valid() {
+		return c, ErrorInvalidCursor
+	}
+
+	return c, nil
+}
+
+func encodeCursor(c cursor) string {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// lookup finds the value at a dotted path in a document.
+func lookup(doc bson.M, path string) interface{} {
+	parts := strings.Split(path, ".")
+
+	var value interface{} = doc
+	for _, part := range parts {
+		m, ok := value.(bson.M)
+		if !ok {
+			return nil
+		}
+
+		value = m[part]
+	}
+
+	return value
+}
+
+// afterCursor returns a query matching the documents sorted after c.
+// Mongo sorts null and missing fields before everything else, but
+// comparison operators never match them, nor match values against null.
+func afterCursor(field string, desc bool, c cursor) bson.M {
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+
+	if field == "_id" {
+		return bson.M{"_id": bson.M{op: c.Id}}
+	}
+
+	// {$in: [null]} matches both null and missing fields.
+	null := bson.M{"$in": []interface{}{nil}}
+
+	if c.Value == nil {
+		after := []bson.M{{field: null, "_id": bson.M{op: c.Id}}}
+		if !desc {
+			after = append(after, bson.M{field: bson.M{"$ne": nil}})
+		}
+
+		return bson.M{"$or": after}
+	}
+
+	after := []bson.M{
+		{field: bson.M{op: c.Value}},
+		{field: c.Value, "_id": bson.M{op: c.Id}},
+	}
+	if desc {
+		after = append(after, bson.M{field: null})
+	}
+
+	return bson.M{"$or": after}
+}
+
+// list runs a paged query. Documents are sorted by the requested field and
+// then by id, which makes the cursor unambiguous. The raw documents are
+// returned for the caller to decode along with the cursor for the next
+// page, if any.
+func list(collection *mgo.Collection, query bson.M, sortFields map[string]string, options ListOptions) ([]bson.Raw, string, error) {
+	field := "_id"
+	desc := false
+
+	if options.Sort != "" {
+		name := strings.TrimPrefix(options.Sort, "-")
+		desc = name != options.Sort
+
+		var found bool
+		field, found = sortFields[name]
+		if !found {
+			return nil, "", ErrorInvalidSort
+		}
+	}
+
+	sort := []string{field, "_id"}
+	if desc {
+		sort = []string{"-" + field, "-_id"}
+	}
+
+	if options.Cursor != "" {
+		c, err := decodeCursor(options.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		query = bson.M{"$and": []bson.M{query, afterCursor(field, desc, c)}}
+	}
+
+	q := collection.Find(query).Sort(sort...)
+	if options.Limit > 0 {
+		// Ask for one more to learn if there is a next page.
+		q = q.Limit(options.Limit + 1)
+	}
+
+	var raws []bson.Raw
+	err := q.All(&raws)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if options.Limit > 0 && len(raws) > options.Limit {
+		raws = raws[:options.Limit]
+
+		var last bson.M
+		err = raws[len(raws)-1].Unmarshal(&last)
+		if err != nil {
+			return nil, "", err
+		}
+
+		id, _ := last["_id"].(bson.ObjectId)
+		next = encodeCursor(cursor{Value: lookup(last, field), Id: id})
+	}
+
+	return raws, next, nil
+}
+
+// searchRegex returns a case insensitive regular expression matching
+// search literally.
+func searchRegex(search string) bson.RegEx {
+	return bson.RegEx{Pattern: regexp.QuoteMeta(search), Options: "i"}
+}
+
+// ListMonitors returns a page of monitors and the cursor for the next
+// page. The search string is matched against monitor names, agent ids,
+// result text and the names of the hosts.
+func ListMonitors(options ListOptions) ([]Monitor, string, error) {
+	query := options.Filter.Query()
+
+	if options.Search != "" {
+		re := searchRegex(options.Search)
+
+		var hosts []struct {
+			Id bson.ObjectId `bson:"_id"`
+		}
+		err := hostCollection.Find(bson.M{"name": re}).Select(bson.M{"_id": 1}).All(&hosts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		hostIds := make([]bson.ObjectId, len(hosts))
+		for i, host := range hosts {
+			hostIds[i] = host.Id
+		}
+
+		query = bson.M{"$and": []bson.M{query, {"$or": []bson.M{
+			{"name": re},
+			{"agent.agentId": re},
+			{"lastresult.text": re},
+			{"hostId": bson.M{"$in": hostIds}},
+		}}}}
+	}
+
+	raws, next, err := list(monitorCollection, query, monitorSortFields, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	monitors := make([]Monitor, len(raws))
+	for i, raw := range raws {
+		err = raw.Unmarshal(&monitors[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return monitors, next, nil
+}
+
+// ListHosts returns a page of hosts and the cursor for the next page. The
+// search string is matched against host names and transport ids.
+func ListHosts(options ListOptions) ([]Host, string, error) {
+	query := bson.M{}
+
+	if options.Filter.HostId != "" {
+		query["_id"] = bson.ObjectIdHex(options.Filter.HostId)
+	}
+
+	if options.Search != "" {
+		re := searchRegex(options.Search)
+		query["$or"] = []bson.M{
+			{"name": re},
+			{"transportId": re},
+		}
+	}
+
+	raws, next, err := list(hostCollection, query, hostSortFields, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hosts := make([]Host, len(raws))
+	for i, raw := range raws {
+		err = raw.Unmarshal(&hosts[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return hosts, next, nil
+}
@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"sort"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type (
+	testDoc struct {
+		id    bson.ObjectId
+		value interface{}
+	}
+)
+
+// compareValues orders like Mongo for ints and null: null first.
+func compareValues(a interface{}, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	return a.(int) - b.(int)
+}
+
+// matches evaluates the subset of Mongo queries produced by afterCursor.
+// Comparison operators only match values of the same type, like in
+// Mongo.
+func matches(t *testing.T, doc testDoc, query bson.M) bool {
+	for key, cond := range query {
+		if key == "$or" {
+			found := false
+			for _, q := range cond.([]bson.M) {
+				found = found || matches(t, doc, q)
+			}
+
+			if !found {
+				return false
+			}
+
+			continue
+		}
+
+		var value interface{} = doc.value
+		if key == "_id" {
+			value = doc.id
+		}
+
+		ops, ok := cond.(bson.M)
+		if !ok {
+			ops = bson.M{"$eq": cond}
+		}
+
+		for op, arg := range ops {
+			if op == "$in" {
+				// Only {$in: [null]} is used.
+				if value != nil {
+					return false
+				}
+
+				continue
+			}
+
+			var c int
+			comparable := true
+
+			if id, ok := value.(bson.ObjectId); ok {
+				c = compareIds(id, arg.(bson.ObjectId))
+			} else if arg != nil && value != nil {
+				c = compareValues(value, arg)
+			} else {
+				comparable = value == nil && arg == nil
+			}
+
+			var ok bool
+			switch op {
+			case "$eq":
+				ok = comparable && c == 0
+			case "$ne":
+				ok = !comparable || c != 0
+			case "$gt":
+				ok = comparable && arg != nil && c > 0
+			case "$lt":
+				ok = comparable && arg != nil && c < 0
+			default:
+				t.Fatalf("Unsupported operator %s", op)
+			}
+
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func compareIds(a bson.ObjectId, b bson.ObjectId) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func TestAfterCursor(t *testing.T) {
+	var docs []testDoc
+	for i, value := range []interface{}{3, nil, 1, 3, nil, 2, nil, 1} {
+		docs = append(docs, testDoc{id: bson.ObjectId([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(i)}), value: value})
+	}
+
+	for _, desc := range []bool{false, true} {
+		sorted := append([]testDoc(nil), docs...)
+		sort.Slice(sorted, func(i, j int) bool {
+			c := compareValues(sorted[i].value, sorted[j].value)
+			if c == 0 {
+				c = compareIds(sorted[i].id, sorted[j].id)
+			}
+
+			if desc {
+				return c > 0
+			}
+
+			return c < 0
+		})
+
+		// Page one document at a time, and expect to see them all in
+		// order.
+		var seen []testDoc
+		var next *cursor
+		for len(seen) <= len(docs) {
+			var page []testDoc
+			for _, doc := range sorted {
+				if next == nil || matches(t, doc, afterCursor("value", desc, *next)) {
+					page = append(page, doc)
+				}
+			}
+
+			if len(page) == 0 {
+				break
+			}
+
+			seen = append(seen, page[0])
+
+			// The cursor goes through encoding like in list().
+			c, err := decodeCursor(encodeCursor(cursor{Value: page[0].value, Id: page[0].id}))
+			if err != nil {
+				t.Fatalf("decodeCursor() returned %s", err.Error())
+			}
+			next = &c
+		}
+
+		if len(seen) != len(sorted) {
+			t.Fatalf("desc=%v: Expected %d documents, got %d: %v", desc, len(sorted), len(seen), seen)
+		}
+
+		for i := range sorted {
+			if seen[i].id != sorted[i].id {
+				t.Errorf("desc=%v: Expected %v at %d, got %v", desc, sorted[i], i, seen[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Maintenance is a planned period where failures of the selected
+	// monitors are excluded from availability numbers.
+	Maintenance struct {
+		Id      bson.ObjectId `json:"id" bson:"_id"`
+		Start   time.Time     `json:"start"`
+		End     time.Time     `json:"end"`
+		Comment string        `json:"comment"`
+		Filter  Filter        `json:"filter"`
+	}
+)
+
+var (
+	ErrorInvalidPeriod error = errors.New("End must be after start")
+)
+
+func AddMaintenance(m *Maintenance) error {
+	if !m.End.After(m.Start) {
+		return ErrorInvalidPeriod
+	}
+
+	err := m.Filter.Compile()
+	if err != nil {
+		return err
+	}
+
+	m.Id = bson.NewObjectId()
+
+	broadcast("maintenanceadd", *m)
+
+	return maintenanceCollection.Insert(m)
+}
+
+func DeleteMaintenance(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrorInvalidId
+	}
+
+	broadcast("maintenancedelete", id)
+
+	return maintenanceCollection.RemoveId(bson.ObjectIdHex(id))
+}
+
+// GetMaintenance returns maintenance windows overlapping the period from
+// from to to. The filters are compiled.
+func GetMaintenance(from time.Time, to time.Time) []Maintenance {
+	windows := []Maintenance{}
+
+	err := maintenanceCollection.Find(bson.M{
+		"start": bson.M{"$lt": to},
+		"end":   bson.M{"$gt": from},
+	}).Sort("start").All(&windows)
+	if err != nil {
+		logger.Red("monitor", "Error getting maintenance from Mongo: %s", err.Error())
+	}
+
+	for i := range windows {
+		windows[i].Filter.Compile()
+	}
+
+	return windows
+}
+
+// InMaintenance returns true if mon is covered by a maintenance window at
+// t.
+func InMaintenance(mon *Monitor, t time.Time) bool {
+	for _, m := range GetMaintenance(t, t.Add(time.Nanosecond)) {
+		if m.Filter.MatchMonitor(mon) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/metrics"
+	"github.com/abrander/alerto/plugins"
+)
+
+var (
+	checksTotal       = metrics.NewCounter("alerto_checks_total", "Number of checks run by the scheduler.", "agent_id", "status")
+	checkSecondsTotal = metrics.NewCounter("alerto_check_seconds_total", "Total time spent running checks.", "agent_id")
+	checksInFlight    = metrics.NewGauge("alerto_checks_in_flight", "Number of checks currently running.")
+	schedulerSeconds  = metrics.NewGauge("alerto_scheduler_iteration_seconds", "Time spent on the latest scheduler iteration.")
+)
+
+func init() {
+	metrics.Register(metrics.CollectorFunc(collectMonitors))
+}
+
+func statusLabel(status plugins.Status) string {
+	if status == plugins.Ok {
+		return "ok"
+	}
+
+	return "failed"
+}
+
+// monitorLabels returns the labels identifying a monitor in metrics. User
+// labels are prefixed by "label_". Keys that are the same once sanitized,
+// like "a-b" and "a_b", are told apart by a suffix, "_2" and up, in the
+// sorted order of the keys.
+func monitorLabels(mon *Monitor, hostName string) metrics.Labels {
+	labels := metrics.Labels{
+		"monitor_id":   mon.Id.Hex(),
+		"monitor_name": mon.Name,
+		"host":         hostName,
+		"agent_id":     mon.Agent.AgentId,
+	}
+
+	keys := make([]string, 0, len(mon.Labels))
+	for key := range mon.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := "label_" + metrics.SanitizeName(key)
+
+		unique := name
+		for i := 2; taken(labels, unique); i++ {
+			unique = name + "_" + strconv.Itoa(i)
+		}
+
+		labels[unique] = mon.Labels[key]
+	}
+
+	return labels
+}
+
+func taken(labels metrics.Labels, name string) bool {
+	_, found := labels[name]
+
+	return found
+}
+
+func withLabel(labels metrics.Labels, key string, value string) metrics.Labels {
+	l := make(metrics.Labels, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[key] = value
+
+	return l
+}
+
+// collectMonitors exposes the latest result of every monitor.
+func collectMonitors() []metrics.Family {
+	hostNames := make(map[bson.ObjectId]string)
+	for _, host := range GetAllHosts() {
+		hostNames[host.Id] = host.Name
+	}
+
+	status := metrics.Family{
+		Name: "alerto_monitor_status",
+		Help: "Status of the latest check. 0 is ok.",
+		Type: metrics.Gauge,
+	}
+
+	paused := metrics.Family{
+		Name: "alerto_monitor_paused",
+		Help: "1 if the monitor is paused.",
+		Type: metrics.Gauge,
+	}
+
+	duration := metrics.Family{
+		Name: "alerto_monitor_check_duration_seconds",
+		Help: "Duration of the latest check.",
+		Type: metrics.Gauge,
+	}
+
+	age := metrics.Family{
+		Name: "alerto_monitor_last_check_age_seconds",
+		Help: "Time since the latest check.",
+		Type: metrics.Gauge,
+	}
+
+	measurement := metrics.Family{
+		Name: "alerto_monitor_measurement",
+		Help: "Measurements from the latest check.",
+		Type: metrics.Gauge,
+	}
+
+	now := time.Now()
+
+	for _, mon := range GetAllMonitors() {
+		labels := monitorLabels(&mon, hostNames[mon.HostId])
+
+		p := 0.0
+		if mon.Paused {
+			p = 1.0
+		}
+		paused.Samples = append(paused.Samples, metrics.Sample{Labels: labels, Value: p})
+
+		if mon.LastCheck.IsZero() {
+			continue
+		}
+
+		status.Samples = append(status.Samples, metrics.Sample{Labels: labels, Value: float64(mon.LastResult.Status)})
+		duration.Samples = append(duration.Samples, metrics.Sample{Labels: labels, Value: mon.LastResult.Duration.Seconds()})
+		age.Samples = append(age.Samples, metrics.Sample{Labels: labels, Value: now.Sub(mon.LastCheck).Seconds()})
+
+		if mon.LastResult.Measurements != nil {
+			for key, value := range *mon.LastResult.Measurements {
+				l := withLabel(labels, "key", key)
+				if value.Unit != "" {
+					l["unit"] = string(value.Unit)
+				}
+
+				measurement.Samples = append(measurement.Samples, metrics.Sample{
+					Labels: l,
+					Value:  value.Value,
+				})
+			}
+		}
+	}
+
+	return []metrics.Family{status, paused, duration, age, measurement}
+}
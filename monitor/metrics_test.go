@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func TestMonitorLabels(t *testing.T) {
+	mon := &Monitor{
+		Id:     bson.NewObjectId(),
+		Agent:  plugins.Job{AgentId: "http"},
+		Labels: Labels{"team-name": "a", "team_name": "b", "team.name": "c", "env": "prod"},
+	}
+
+	labels := monitorLabels(mon, "web1")
+
+	expected := map[string]string{
+		"label_env":         "prod",
+		"label_team_name":   "a",
+		"label_team_name_2": "c",
+		"label_team_name_3": "b",
+	}
+
+	for name, value := range expected {
+		if labels[name] != value {
+			t.Errorf("Expected %s=%s, got %v", name, value, labels)
+		}
+	}
+
+	if len(labels) != 4+len(expected) {
+		t.Errorf("Expected %d labels, got %v", 4+len(expected), labels)
+	}
+}
@@ -18,29 +18,54 @@ type (
 	Monitor struct {
 		Id         bson.ObjectId  `json:"id" bson:"_id"`
 		HostId     bson.ObjectId  `json:"hostId" bson:"hostId"`
+		Name       string         `json:"name" bson:"name"`
 		Interval   time.Duration  `json:"interval"`
 		Agent      plugins.Job    `json:"agent"`
 		LastCheck  time.Time      `json:"lastCheck"`
 		NextCheck  time.Time      `json:"nextCheck"`
 		LastResult plugins.Result `json:"lastResult"`
+		Labels     Labels         `json:"labels" bson:"labels"`
+		Paused     bool           `json:"paused" bson:"paused"`
+		Ack        *Ack           `json:"ack" bson:"ack"`
+
+		// FailureThreshold is the number of consecutive failures needed
+		// for a hard failure, which opens an incident. Zero means one.
+		FailureThreshold int `json:"failureThreshold" bson:"failureThreshold"`
+		Failures         int `json:"failures" bson:"failures"`
+
+		// State is kept for stateful agents. It is not part of the API,
+		// so it is reset when a monitor is updated.
+		State plugins.State `json:"-" bson:"state,omitempty"`
+	}
+
+	// Ack is set when someone has acknowledged a failing monitor. It is
+	// cleared when the monitor recovers.
+	Ack struct {
+		By      string    `json:"by"`
+		Time    time.Time `json:"time"`
+		Comment string    `json:"comment"`
 	}
 
 	Change struct {
+		Seq     uint64      `json:"seq"`
 		Type    string      `json:"type"`
 		Payload interface{} `json:"payload"`
 	}
 )
 
 var (
-	sess              *mgo.Session
-	db                *mgo.Database
-	hostCollection    *mgo.Collection
-	monitorCollection *mgo.Collection
-
-	ErrorInvalidId error = errors.New("Invalid id")
-
-	channelLock sync.Mutex
-	changes     []chan Change
+	sess                  *mgo.Session
+	db                    *mgo.Database
+	hostCollection        *mgo.Collection
+	monitorCollection     *mgo.Collection
+	resultCollection      *mgo.Collection
+	stateCollection       *mgo.Collection
+	maintenanceCollection *mgo.Collection
+	incidentCollection    *mgo.Collection
+
+	ErrorInvalidId   error = errors.New("Invalid id")
+	ErrorHostInUse   error = errors.New("Host is used by one or more monitors")
+	ErrorUnknownHost error = errors.New("Unknown host")
 )
 
 func init() {
@@ -53,6 +78,17 @@ func init() {
 	db = sess.DB("alerto")
 	hostCollection = db.C("hosts")
 	monitorCollection = db.C("monitors")
+	resultCollection = db.C("results")
+	stateCollection = db.C("states")
+	maintenanceCollection = db.C("maintenance")
+	incidentCollection = db.C("incidents")
+
+	resultCollection.EnsureIndex(mgo.Index{Key: []string{"monitorId", "-time"}})
+	resultCollection.EnsureIndex(mgo.Index{Key: []string{"time"}, ExpireAfter: ResultRetention})
+	stateCollection.EnsureIndex(mgo.Index{Key: []string{"monitorId", "-time"}})
+	maintenanceCollection.EnsureIndex(mgo.Index{Key: []string{"end"}})
+	incidentCollection.EnsureIndex(mgo.Index{Key: []string{"monitorId", "open"}})
+	incidentCollection.EnsureIndex(mgo.Index{Key: []string{"-opened"}})
 }
 
 func GetAllMonitors() []Monitor {
@@ -82,76 +118,66 @@ func GetMonitor(id string) (Monitor, error) {
 	return monitor, nil
 }
 
-func UpdateMonitor(mon *Monitor) error {
-	change := Change{
-		Type:    "monchange",
-		Payload: *mon,
+func GetMonitorsByHost(hostId string) ([]Monitor, error) {
+	var monitors []Monitor
+
+	if !bson.IsObjectIdHex(hostId) {
+		return monitors, ErrorInvalidId
 	}
 
-	channelLock.Lock()
-	for _, ch := range changes {
-		ch <- change
+	err := monitorCollection.Find(bson.M{"hostId": bson.ObjectIdHex(hostId)}).All(&monitors)
+
+	return monitors, err
+}
+
+func UpdateMonitor(mon *Monitor) error {
+	err := mon.Agent.Validate()
+	if err != nil {
+		return err
 	}
-	channelLock.Unlock()
+
+	broadcast("monchange", *mon)
 
 	return monitorCollection.UpdateId(mon.Id, mon)
 }
 
 func AddMonitor(mon *Monitor) error {
-	mon.Id = bson.NewObjectId()
-
-	change := Change{
-		Type:    "monadd",
-		Payload: *mon,
+	err := mon.Agent.Validate()
+	if err != nil {
+		return err
 	}
 
-	channelLock.Lock()
-	for _, ch := range changes {
-		ch <- change
-	}
-	channelLock.Unlock()
+	mon.Id = bson.NewObjectId()
+
+	broadcast("monadd", *mon)
 
 	return monitorCollection.Insert(mon)
 }
 
+// Redacted returns a copy of mon with the secret arguments of the agent
+// redacted.
+func (mon Monitor) Redacted() Monitor {
+	mon.Agent = mon.Agent.Redacted()
+
+	return mon
+}
+
 func DeleteMonitor(id string) error {
 	if !bson.IsObjectIdHex(id) {
 		return ErrorInvalidId
 	}
 
-	change := Change{
-		Type:    "mondelete",
-		Payload: id,
-	}
+	broadcast("mondelete", id)
 
-	channelLock.Lock()
-	for _, ch := range changes {
-		ch <- change
+	err := monitorCollection.RemoveId(bson.ObjectIdHex(id))
+	if err != nil {
+		return err
 	}
-	channelLock.Unlock()
-
-	return monitorCollection.RemoveId(bson.ObjectIdHex(id))
-}
 
-func SubscribeChanges() chan Change {
-	channel := make(chan Change)
-	channelLock.Lock()
-	changes = append(changes, channel)
-	channelLock.Unlock()
+	// Nobody will see the monitor recover.
+	closeOpenIncident(bson.ObjectIdHex(id), "DELETED", "Monitor deleted")
 
-	return channel
-}
-
-func UnsubscribeChanges(ch chan Change) {
-	channelLock.Lock()
-
-	for i, c := range changes {
-		if ch == c {
-			changes = append(changes[:i], changes[i+1:]...)
-			break
-		}
-	}
-	channelLock.Unlock()
+	return nil
 }
 
 func Loop(wg sync.WaitGroup) {
@@ -171,6 +197,8 @@ func Loop(wg sync.WaitGroup) {
 		logger.Yellow("monitor", "Added localhost transport with id %s", host.Id.String())
 	}
 
+	CheckConsistency()
+
 	ticker := time.Tick(time.Millisecond * 100)
 
 	inFlight := make(map[bson.ObjectId]bool)
@@ -198,38 +226,77 @@ func Loop(wg sync.WaitGroup) {
 				mon.NextCheck = t.Add(checkIn)
 				logger.Yellow("monitor", "%s %s: Delaying first check by %s", mon.Id.Hex(), mon.Agent.AgentId, checkIn)
 
-				err = UpdateMonitor(&mon)
+				_, err = setFields(mon.Id, bson.M{"nextcheck": mon.NextCheck})
 				if err != nil {
-					logger.Red("Error updating: %v", err.Error())
+					logger.Red("monitor", "Error updating: %s", err.Error())
 				}
+			} else if mon.Paused {
+				// paused monitors are never checked
 			} else if wait < 0 {
 				inFlightLock.Lock()
 				inFlight[mon.Id] = true
 				inFlightLock.Unlock()
+				checksInFlight.Add(1)
 
 				go func(mon Monitor) {
+					var r plugins.Result
+					var state plugins.State
 					var host Host
-					hostCollection.FindId(mon.HostId).One(&host)
-					r := mon.Agent.Run(host.Transport)
+					err := hostCollection.FindId(mon.HostId).One(&host)
+					if err == mgo.ErrNotFound {
+						r = plugins.NewResult(plugins.Failed, nil, "%s: %s", ErrorUnknownHost.Error(), mon.HostId.Hex())
+					} else if err != nil {
+						r = plugins.NewResult(plugins.Failed, nil, "%s", err.Error())
+					} else {
+						r, state = mon.Agent.RunWithState(host.Transport, mon.State)
+					}
+
+					checksTotal.Inc(mon.Agent.AgentId, statusLabel(r.Status))
+					checkSecondsTotal.Add(r.Duration.Seconds(), mon.Agent.AgentId)
+
 					if r.Status == plugins.Ok {
 						logger.Green("monitor", "%s %s: %s [%s]: %s", mon.Id.Hex(), mon.Agent.AgentId, r.Text, r.Duration, r.Measurements)
 					} else {
 						logger.Red("monitor", "%s %s: %s [%s]", mon.Id.Hex(), mon.Agent.AgentId, r.Text, r.Duration)
 					}
-					mon.LastResult = r
-					mon.LastCheck = t
-					mon.NextCheck = t.Add(mon.Interval)
+					fields := bson.M{
+						"lastresult": r,
+						"lastcheck":  t,
+						"nextcheck":  t.Add(mon.Interval),
+					}
 
-					err = UpdateMonitor(&mon)
+					// State is kept if the agent never ran.
+					if _, ok := mon.Agent.Agent.(plugins.StatefulAgent); ok && err == nil {
+						fields["state"] = state
+					}
+
+					if r.Status == plugins.Ok {
+						fields["ack"] = nil
+						fields["failures"] = 0
+					} else {
+						fields["failures"] = mon.Failures + 1
+					}
+
+					// Only the fields owned by the scheduler are updated, to
+					// avoid overwriting changes made while the check ran.
+					updated, err := setFields(mon.Id, fields)
 					if err != nil {
 						logger.Red("monitor", "Error updating: %s", err.Error())
+					} else {
+						recordResult(mon.Id, r, t)
+						recordState(mon.Id, r.Status, t)
+						updateIncident(&updated, r, t)
+						callResultHandlers(&updated, &host, r)
 					}
 					inFlightLock.Lock()
 					delete(inFlight, mon.Id)
 					inFlightLock.Unlock()
+					checksInFlight.Add(-1)
 				}(mon)
 			}
 		}
+
+		schedulerSeconds.Set(time.Since(t).Seconds())
 	}
 
 	wg.Done()
@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"sync"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// ResultHandler is called by the scheduler every time a check has
+	// finished and the result is stored. mon contains the new result.
+	// Handlers are called synchronously from the scheduler and must not
+	// block.
+	ResultHandler func(mon *Monitor, host *Host, result plugins.Result)
+)
+
+var (
+	resultHandlersLock sync.RWMutex
+	resultHandlers     []ResultHandler
+)
+
+// AddResultHandler registers a handler for finished checks.
+func AddResultHandler(handler ResultHandler) {
+	resultHandlersLock.Lock()
+	resultHandlers = append(resultHandlers, handler)
+	resultHandlersLock.Unlock()
+}
+
+func callResultHandlers(mon *Monitor, host *Host, result plugins.Result) {
+	resultHandlersLock.RLock()
+	defer resultHandlersLock.RUnlock()
+
+	for _, handler := range resultHandlers {
+		handler(mon, host, result)
+	}
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type (
+	// Email sends notifications using SMTP. Attachments are sent as MIME
+	// parts.
+	Email struct {
+		address  string
+		from     string
+		to       []string
+		username string
+		password string
+	}
+)
+
+func NewEmail(address string, from string, to []string, username string, password string) *Email {
+	return &Email{
+		address:  address,
+		from:     from,
+		to:       to,
+		username: username,
+		password: password,
+	}
+}
+
+func (e *Email) message(n Notification) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", e.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	part.Write([]byte(n.Text))
+
+	for _, a := range n.Attachments {
+		part, err = w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Lines in mail must be kept short.
+		encoded := base64.StdEncoding.EncodeToString(a.Content)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e *Email) Notify(n Notification) error {
+	msg, err := e.message(n)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, _ := net.SplitHostPort(e.address)
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+
+	return smtp.SendMail(e.address, auth, e.from, e.to, msg)
+}
@@ -0,0 +1,18 @@
+package notify
+
+import (
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Log writes notifications to the log. It is mostly useful for
+	// testing.
+	Log struct {
+	}
+)
+
+func (l *Log) Notify(n Notification) error {
+	logger.Error("notify", "%s\n%s", n.Subject, n.Text)
+
+	return nil
+}
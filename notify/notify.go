@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	Attachment struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"contentType"`
+		Content     []byte `json:"content"`
+	}
+
+	// Notification is something to tell. Data is a machine readable
+	// version used by notifiers supporting it.
+	Notification struct {
+		Subject     string       `json:"subject"`
+		Text        string       `json:"text"`
+		Data        interface{}  `json:"data,omitempty"`
+		Attachments []Attachment `json:"attachments,omitempty"`
+	}
+
+	Notifier interface {
+		Notify(n Notification) error
+	}
+)
+
+var (
+	lock      sync.RWMutex
+	notifiers = make(map[string]Notifier)
+)
+
+// New creates a notifier from its configuration.
+func New(c config.Notifier) (Notifier, error) {
+	switch c.Type {
+	case "webhook":
+		return NewWebhook(c.URL), nil
+	case "email":
+		return NewEmail(c.Address, c.From, c.To, c.Username, c.Password), nil
+	case "log":
+		return &Log{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown notifier type '%s'", c.Type)
+}
+
+// Setup creates the configured notifiers and makes them available by name.
+func Setup(configs []config.Notifier) {
+	for _, c := range configs {
+		n, err := New(c)
+		if err != nil {
+			logger.Error("notify", "%s: %s", c.Name, err.Error())
+			continue
+		}
+
+		Register(c.Name, n)
+	}
+}
+
+func Register(name string, n Notifier) {
+	lock.Lock()
+	notifiers[name] = n
+	lock.Unlock()
+}
+
+func Get(name string) (Notifier, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	n, found := notifiers[name]
+
+	return n, found
+}
+
+// Names returns the names of all registered notifiers.
+func Names() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Send sends n using the named notifier.
+func Send(name string, n Notification) error {
+	notifier, found := Get(name)
+	if !found {
+		return fmt.Errorf("unknown notifier '%s'", name)
+	}
+
+	return notifier.Notify(n)
+}
@@ -0,0 +1,116 @@
+package cpu
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func init() {
+	plugins.Register("cpu", NewCPU)
+}
+
+func NewCPU() plugins.Plugin {
+	return new(CPU)
+}
+
+type (
+	CPU struct {
+	}
+)
+
+var (
+	// The columns of the cpu line in /proc/stat.
+	columns = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+)
+
+func (c CPU) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{
+		Name:        "CPU",
+		Description: "Read CPU usage in percent since the last check",
+	}
+}
+
+func (c *CPU) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
+	r, _ := c.RunWithState(transport, request, plugins.State{})
+
+	return r
+}
+
+// readStat reads the aggregated CPU counters from /proc/stat.
+func readStat(transport plugins.Transport) (plugins.Counters, error) {
+	file, err := transport.ReadFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(plugins.Counters)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		data := strings.Fields(scanner.Text())
+		if len(data) < 2 || data[0] != "cpu" {
+			continue
+		}
+
+		for i, value := range data[1:] {
+			if i >= len(columns) {
+				break
+			}
+
+			counters[columns[i]], err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return counters, scanner.Err()
+}
+
+// RunWithState calculates usage from the difference between the counters
+// read now and the counters read by the previous run. The first run, and
+// the first run after a reboot, only records the counters.
+func (c *CPU) RunWithState(transport plugins.Transport, request plugins.Request, state plugins.State) (plugins.Result, plugins.State) {
+	counters, err := readStat(transport)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error()), state
+	}
+
+	next, err := plugins.NewState(time.Now(), counters)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error()), state
+	}
+
+	var previous plugins.Counters
+	if state.First() || state.Decode(&previous) != nil {
+		return plugins.NewResult(plugins.Ok, nil, "First sample recorded"), next
+	}
+
+	delta, reset := counters.Delta(previous)
+
+	var total uint64
+	for _, value := range delta {
+		total += value
+	}
+
+	if reset || total == 0 {
+		return plugins.NewResult(plugins.Ok, nil, "Counters reset, sample recorded"), next
+	}
+
+	m := plugins.NewMeasurementCollection()
+	for key, value := range delta {
+		m.AddPercent(key, float64(value)*100.0/float64(total))
+	}
+
+	used := 100.0 - float64(delta["idle"]+delta["iowait"])*100.0/float64(total)
+	m.AddPercent("used", used)
+
+	return plugins.NewResult(plugins.Ok, m, "%.1f%% used", used), next
+}
+
+// Ensure compliance
+var _ plugins.StatefulAgent = (*CPU)(nil)
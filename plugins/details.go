@@ -0,0 +1,64 @@
+package plugins
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+type (
+	// Details is structured data about a result, like resolved addresses
+	// or response headers. Values must be encodable as JSON.
+	Details map[string]interface{}
+)
+
+var (
+	// MaxDetailsSize limits the size of details encoded as JSON.
+	MaxDetailsSize = 16 * 1024
+)
+
+// size returns the size of v encoded as JSON.
+func size(v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+
+	return len(data), err
+}
+
+// limit returns details no larger than max, by removing the largest
+// entries. The keys of removed entries are listed in "truncated".
+func (d Details) limit(max int) Details {
+	total, err := size(d)
+	if err != nil {
+		return Details{"error": err.Error()}
+	}
+
+	if total <= max {
+		return d
+	}
+
+	sizes := make(map[string]int, len(d))
+	keys := make([]string, 0, len(d))
+	limited := make(Details, len(d))
+	for key, value := range d {
+		sizes[key], _ = size(value)
+		keys = append(keys, key)
+		limited[key] = value
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return sizes[keys[i]] > sizes[keys[j]]
+	})
+
+	truncated := []string{}
+	for _, key := range keys {
+		delete(limited, key)
+		truncated = append(truncated, key)
+		limited["truncated"] = truncated
+
+		total, _ = size(limited)
+		if total <= max {
+			break
+		}
+	}
+
+	return limited
+}
@@ -17,8 +17,8 @@ func NewDns() plugins.Plugin {
 
 type (
 	Dns struct {
-		Target     string `json:"target" description:"The name to resolve"`
-		RecordType string `json:"recordType" description:"The record type to lookup" enum:"A,AAAA,A*"`
+		Target     string `json:"target" description:"The name to resolve" required:"true"`
+		RecordType string `json:"recordType" description:"The record type to lookup" enum:"A,AAAA,A*" default:"A"`
 	}
 )
 
@@ -97,7 +97,15 @@ func (i *Dns) Run(transport plugins.Transport, request plugins.Request) plugins.
 	}
 
 	if len(entries) > 0 {
-		return plugins.NewResult(plugins.Ok, plugins.NewMeasurementCollection("time", time.Now().Sub(start)), "%d addresses", len(entries))
+		addresses := make([]string, len(entries))
+		for i, ip := range entries {
+			addresses[i] = ip.String()
+		}
+
+		r := plugins.NewResult(plugins.Ok, plugins.NewMeasurementCollection("time", time.Now().Sub(start)), "%d addresses", len(entries))
+		r.Details = plugins.Details{"addresses": addresses}
+
+		return r
 	} else {
 		return plugins.NewResult(plugins.Failed, plugins.NewMeasurementCollection("time", time.Now().Sub(start)), "no addresses")
 	}
@@ -0,0 +1,285 @@
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Command describes a command to execute on a host.
+	Command struct {
+		Path string
+		Args []string
+
+		// Stdin is read by the command if set.
+		Stdin io.Reader
+
+		// Env is added to the environment of the command.
+		Env map[string]string
+
+		// Dir is the working directory. The default depends on the
+		// transport.
+		Dir string
+
+		// Sudo runs the command as root using sudo, which must not
+		// ask for a password.
+		Sudo bool
+
+		// StdoutLimit and StderrLimit limit the output kept. Output
+		// beyond the limits is discarded. Zero means
+		// DefaultOutputLimit.
+		StdoutLimit int64
+		StderrLimit int64
+
+		// Timeout kills the command if it runs for longer. Zero means
+		// no timeout.
+		Timeout time.Duration
+	}
+
+	// Process is a command started by a transport. Output can be read
+	// while the command runs.
+	Process struct {
+		Stdout *Output
+		Stderr *Output
+
+		done     chan struct{}
+		exitCode int
+		err      error
+	}
+
+	// Output is written by a running command and read by agents. Writes
+	// never block, so a command can't stall on output nobody reads.
+	Output struct {
+		lock    sync.Mutex
+		cond    *sync.Cond
+		buf     bytes.Buffer
+		limit   int64
+		written int64
+		closed  bool
+	}
+
+	// ExecResult is the buffered output of a command.
+	ExecResult struct {
+		Stdout   []byte
+		Stderr   []byte
+		ExitCode int
+
+		// Truncated is true if output was discarded.
+		Truncated bool
+	}
+)
+
+var (
+	// DefaultOutputLimit is used when a command has no output limit.
+	DefaultOutputLimit int64 = 1 << 20
+
+	ErrorInvalidEnvironment error = errors.New("Invalid environment variable name")
+	ErrorNoCommand          error = errors.New("No command given")
+
+	envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// NewOutput returns an output keeping at most limit bytes.
+func NewOutput(limit int64) *Output {
+	if limit <= 0 {
+		limit = DefaultOutputLimit
+	}
+
+	o := &Output{limit: limit}
+	o.cond = sync.NewCond(&o.lock)
+
+	return o
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.written < o.limit {
+		keep := int64(len(p))
+		if o.written+keep > o.limit {
+			keep = o.limit - o.written
+		}
+
+		o.buf.Write(p[:keep])
+		o.cond.Broadcast()
+	}
+
+	o.written += int64(len(p))
+
+	return len(p), nil
+}
+
+// Read blocks until output is available or the command has exited.
+func (o *Output) Read(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for o.buf.Len() == 0 && !o.closed {
+		o.cond.Wait()
+	}
+
+	if o.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return o.buf.Read(p)
+}
+
+// Close is called by transports when no more output will be written.
+func (o *Output) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.closed = true
+	o.cond.Broadcast()
+
+	return nil
+}
+
+// SetTruncated marks the output as truncated, for transports passing on
+// output truncated elsewhere.
+func (o *Output) SetTruncated() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.written <= o.limit {
+		o.written = o.limit + 1
+	}
+}
+
+// Truncated returns true if output beyond the limit was discarded.
+func (o *Output) Truncated() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.written > o.limit
+}
+
+// NewProcess returns a process with output limited as requested by cmd.
+// It is used by transports, which must call Exit when the command exits.
+func NewProcess(cmd Command) *Process {
+	return &Process{
+		Stdout: NewOutput(cmd.StdoutLimit),
+		Stderr: NewOutput(cmd.StderrLimit),
+		done:   make(chan struct{}),
+	}
+}
+
+// Exit records the exit of the process. err should only be set if the
+// exit code is unknown, like when the connection to a host is lost.
+func (p *Process) Exit(exitCode int, err error) {
+	p.exitCode = exitCode
+	p.err = err
+
+	p.Stdout.Close()
+	p.Stderr.Close()
+	close(p.done)
+}
+
+// Wait waits for the command to exit and returns its exit code. A non-zero
+// exit code is not an error.
+func (p *Process) Wait() (int, error) {
+	<-p.done
+
+	return p.exitCode, p.err
+}
+
+// Validate checks cmd before it is executed.
+func (cmd Command) Validate() error {
+	if cmd.Path == "" {
+		return ErrorNoCommand
+	}
+
+	for key := range cmd.Env {
+		if !envName.MatchString(key) {
+			return fmt.Errorf("%s: '%s'", ErrorInvalidEnvironment.Error(), key)
+		}
+	}
+
+	return nil
+}
+
+// Environ returns the environment of cmd as sorted KEY=value pairs.
+func (cmd Command) Environ() []string {
+	env := make([]string, 0, len(cmd.Env))
+	for key, value := range cmd.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+
+	return env
+}
+
+// Argv returns the command line to execute. Commands run with sudo are
+// wrapped in sudo and env, since sudo resets the environment.
+func (cmd Command) Argv() []string {
+	argv := append([]string{cmd.Path}, cmd.Args...)
+
+	if cmd.Sudo {
+		wrapped := []string{"sudo", "-n", "--", "env"}
+		wrapped = append(wrapped, cmd.Environ()...)
+		argv = append(wrapped, argv...)
+	}
+
+	return argv
+}
+
+// ShellQuote quotes s for POSIX shells.
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ShellCommand returns cmd as a line for a POSIX shell, for transports
+// executing commands through a shell.
+func (cmd Command) ShellCommand() (string, error) {
+	err := cmd.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	argv := cmd.Argv()
+	if !cmd.Sudo && len(cmd.Env) > 0 {
+		argv = append(append([]string{"env"}, cmd.Environ()...), argv...)
+	}
+
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = ShellQuote(arg)
+	}
+
+	line := "exec " + strings.Join(quoted, " ")
+	if cmd.Dir != "" {
+		line = "cd " + ShellQuote(cmd.Dir) + " && " + line
+	}
+
+	return line, nil
+}
+
+// Exec executes cmd on transport and waits for it to exit.
+func Exec(transport Transport, cmd Command) (ExecResult, error) {
+	var result ExecResult
+
+	p, err := transport.Exec(cmd)
+	if err != nil {
+		return result, err
+	}
+
+	// Outputs are never blocking the command, so they can be read one at
+	// a time.
+	result.Stdout, _ = ioutil.ReadAll(p.Stdout)
+	result.Stderr, _ = ioutil.ReadAll(p.Stderr)
+
+	result.ExitCode, err = p.Wait()
+	result.Truncated = p.Stdout.Truncated() || p.Stderr.Truncated()
+
+	return result, err
+}
@@ -0,0 +1,76 @@
+package external
+
+import (
+	"encoding/json"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Agent runs an external executable. Arguments are kept as decoded
+	// from JSON and checked against the parameters the executable
+	// describes.
+	Agent struct {
+		executable *executable
+		Arguments  map[string]interface{}
+	}
+)
+
+func (a *Agent) GetInfo() plugins.HumanInfo {
+	return a.executable.description.Info
+}
+
+func (a *Agent) Describe() plugins.Description {
+	return a.executable.description
+}
+
+func (a *Agent) ApplyDefaults() {
+	if a.Arguments == nil {
+		a.Arguments = make(map[string]interface{})
+	}
+
+	plugins.DefaultArguments(a.executable.description.Parameters, a.Arguments)
+}
+
+func (a *Agent) Validate() error {
+	return plugins.ValidateArguments(a.executable.description.Parameters, a.Arguments)
+}
+
+func (a *Agent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Arguments)
+}
+
+func (a *Agent) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &a.Arguments)
+}
+
+func (a *Agent) GetBSON() (interface{}, error) {
+	return a.Arguments, nil
+}
+
+func (a *Agent) SetBSON(raw bson.Raw) error {
+	return raw.Unmarshal(&a.Arguments)
+}
+
+// Run runs the executable on the Alerto server. The transport is not
+// available to external agents.
+func (a *Agent) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
+	var result plugins.Result
+
+	r := runRequest{
+		Arguments: a.Arguments,
+		Timeout:   request.Timeout.Seconds(),
+	}
+
+	err := a.executable.call("run", request.Timeout, r, &result)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error())
+	}
+
+	return result
+}
+
+// Ensure compliance
+var _ plugins.Agent = (*Agent)(nil)
@@ -0,0 +1,216 @@
+// Package external runs agents implemented as executables in the plugins
+// directory.
+//
+// An executable is called with a single argument. "describe" must print a
+// JSON object with "info" (name and description) and "parameters" like
+// plugins.Description. "run" reads a JSON object with "arguments" and
+// "timeout" (in seconds) on stdin and must print a result like
+// {"status": 0, "text": "OK", "measurements": {"time": 0.2}}. Measurements
+// can also be objects like {"value": 0.2, "unit": "s"}, see
+// plugins.Measurement. An optional "details" object is kept as
+// plugins.Details.
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// executable is an external plugin found in the plugins directory.
+	executable struct {
+		path        string
+		description plugins.Description
+	}
+
+	runRequest struct {
+		Arguments map[string]interface{} `json:"arguments"`
+		Timeout   float64                `json:"timeout"`
+	}
+
+	// limitedBuffer fails writes beyond its limit.
+	limitedBuffer struct {
+		bytes.Buffer
+		limit int64
+	}
+)
+
+const (
+	DescribeTimeout = 10 * time.Second
+
+	// DefaultOutputLimit is used if no limit is configured, as all output
+	// is kept in memory.
+	DefaultOutputLimit = 1 << 20
+)
+
+var (
+	ErrorOutputLimit error = errors.New("Output limit exceeded")
+)
+
+// Load registers the executables in the configured plugins directory as
+// agents named after the file, without extension.
+func Load(c config.Plugins) {
+	dir := c.Directory
+	if dir == "" {
+		dir = path.Join(config.ConfigDir, "plugins")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		logger.Error("external", "Error reading plugins directory: %s", err.Error())
+		return
+	}
+
+	for _, f := range files {
+		if f.IsDir() || f.Mode()&0111 == 0 {
+			continue
+		}
+
+		name := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		e := &executable{path: path.Join(dir, f.Name())}
+
+		e.description, err = e.describe()
+		if err != nil {
+			logger.Error("external", "Error describing %s: %s", e.path, err.Error())
+			continue
+		}
+
+		_, exists := plugins.GetPlugin(name)
+		if exists {
+			logger.Error("external", "Ignoring %s, agent '%s' already exists", e.path, name)
+			continue
+		}
+
+		plugins.Register(name, func() plugins.Plugin {
+			return &Agent{executable: e}
+		})
+
+		logger.Yellow("external", "Registered agent '%s' from %s", name, e.path)
+	}
+}
+
+// command returns a command running the executable with the configured
+// resource limits applied by the shell.
+func (e *executable) command(ctx context.Context, call string) *exec.Cmd {
+	limits := config.Current.Plugins
+	script := ""
+
+	if limits.MemoryLimit > 0 {
+		script += fmt.Sprintf("ulimit -v %d && ", (limits.MemoryLimit+1023)/1024)
+	}
+
+	if limits.CPULimit.Duration > 0 {
+		script += fmt.Sprintf("ulimit -t %d && ", int64(math.Ceil(limits.CPULimit.Seconds())))
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script+`exec "$0" "$@"`, e.path, call)
+	cmd.Dir = path.Dir(e.path)
+
+	// Run in a process group of its own, so children are killed on
+	// timeout as well.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	return cmd
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.limit {
+		return 0, ErrorOutputLimit
+	}
+
+	return b.Buffer.Write(p)
+}
+
+// call runs the executable and decodes its output into response.
+func (e *executable) call(call string, timeout time.Duration, request interface{}, response interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := e.command(ctx, call)
+
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+
+		cmd.Stdin = bytes.NewReader(data)
+	}
+
+	limit := config.Current.Plugins.OutputLimit
+	if limit <= 0 {
+		limit = DefaultOutputLimit
+	}
+
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: limit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return fmt.Errorf("%s: %s", err.Error(), msg)
+		}
+
+		return err
+	}
+
+	err = json.Unmarshal(stdout.Bytes(), response)
+	if err != nil {
+		return fmt.Errorf("invalid response: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (e *executable) describe() (plugins.Description, error) {
+	var d plugins.Description
+
+	err := e.call("describe", DescribeTimeout, nil, &d)
+	if err != nil {
+		return d, err
+	}
+
+	if d.Info.Name == "" {
+		d.Info.Name = path.Base(e.path)
+	}
+
+	if d.Parameters == nil {
+		d.Parameters = []plugins.Parameter{}
+	}
+
+	for i := range d.Parameters {
+		if d.Parameters[i].EnumValues == nil {
+			d.Parameters[i].EnumValues = []string{}
+		}
+	}
+
+	return d, nil
+}
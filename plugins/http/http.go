@@ -1,6 +1,8 @@
 package http
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
 	"time"
@@ -8,6 +10,10 @@ import (
 	"github.com/abrander/alerto/plugins"
 )
 
+// maxRedirects is the number of redirects followed, like the default of
+// net/http.
+const maxRedirects = 10
+
 func init() {
 	plugins.Register("http", NewHttp)
 }
@@ -18,7 +24,7 @@ func NewHttp() plugins.Plugin {
 
 type (
 	Http struct {
-		Url string `json:"url" description:"The URL to request"`
+		Url string `json:"url" description:"The URL to request" required:"true" pattern:"^https?://"`
 	}
 
 	instrumentTransport struct {
@@ -83,11 +89,29 @@ func (tr *instrumentTransport) Duration() time.Duration {
 func (h *Http) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
 	start := time.Now()
 
+	redirects := []string{}
+
 	tr := newTransport(transport.Dial)
-	client := &http.Client{Transport: tr}
+	client := &http.Client{
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+
+			redirects = append(redirects, req.URL.String())
+
+			return nil
+		},
+	}
 	resp, err := client.Get(h.Url)
 	if err != nil {
-		return plugins.NewResult(plugins.Failed, plugins.NewMeasurementCollection("time", time.Now().Sub(start)), err.Error())
+		r := plugins.NewResult(plugins.Failed, plugins.NewMeasurementCollection("time", time.Now().Sub(start)), err.Error())
+		if len(redirects) > 0 {
+			r.Details = plugins.Details{"redirects": redirects}
+		}
+
+		return r
 	}
 	defer resp.Body.Close()
 
@@ -98,7 +122,48 @@ func (h *Http) Run(transport plugins.Transport, request plugins.Request) plugins
 		"requestDuration", tr.RequestDuration(),
 	)
 
-	return plugins.NewResult(plugins.Ok, c, "returned %d", resp.StatusCode)
+	r := plugins.NewResult(plugins.Ok, c, "returned %d", resp.StatusCode)
+	r.Details = details(resp, redirects)
+
+	return r
+}
+
+// details describes the response headers, the redirects followed and the
+// TLS connection, if any.
+func details(resp *http.Response, redirects []string) plugins.Details {
+	headers := make(map[string]string, len(resp.Header))
+	for key := range resp.Header {
+		headers[key] = resp.Header.Get(key)
+	}
+
+	d := plugins.Details{
+		"headers": headers,
+	}
+
+	if len(redirects) > 0 {
+		d["redirects"] = redirects
+	}
+
+	if resp.TLS != nil {
+		certificates := []map[string]interface{}{}
+		for _, cert := range resp.TLS.PeerCertificates {
+			certificates = append(certificates, map[string]interface{}{
+				"subject":   cert.Subject.String(),
+				"issuer":    cert.Issuer.String(),
+				"notBefore": cert.NotBefore,
+				"notAfter":  cert.NotAfter,
+				"dnsNames":  cert.DNSNames,
+			})
+		}
+
+		d["tls"] = map[string]interface{}{
+			"version":      tls.VersionName(resp.TLS.Version),
+			"cipherSuite":  tls.CipherSuiteName(resp.TLS.CipherSuite),
+			"certificates": certificates,
+		}
+	}
+
+	return d
 }
 
 // Ensure compliance
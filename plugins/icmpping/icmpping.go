@@ -19,7 +19,7 @@ import (
 
 type (
 	IcmpPing struct {
-		Target  string `json:"target" description:"The IPv4 hostname or IP address to ping"`
+		Target  string `json:"target" description:"The IPv4 hostname or IP address to ping" required:"true"`
 		id      int
 		seq     int
 		payload []byte
@@ -54,9 +54,18 @@ func (job *Job) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	ApplyDefaults(job.Agent)
+
 	return nil
 }
 
+// MarshalJSON encodes job with the secret arguments redacted.
+func (job Job) MarshalJSON() ([]byte, error) {
+	type plain Job
+
+	return json.Marshal(plain(job.Redacted()))
+}
+
 func (job *Job) SetBSON(raw bson.Raw) error {
 	m := make(map[string]bson.Raw)
 
@@ -105,10 +114,50 @@ func (job *Job) SetBSON(raw bson.Raw) error {
 		}
 	}
 
+	ApplyDefaults(job.Agent)
+
 	return nil
 }
 
+// Validate checks the arguments of the job against the agent description.
+func (job *Job) Validate() error {
+	if job.Agent == nil {
+		return invalid("agentId", "missing")
+	}
+
+	return Validate(job.Agent)
+}
+
+// Privileged returns true if the agent of job runs commands or code given
+// in its arguments.
+func (job *Job) Privileged() bool {
+	p, ok := job.Agent.(Privileged)
+
+	return ok && p.Privileged()
+}
+
+// Run runs the job once, without a previous state. Stateful agents are
+// run twice, SampleInterval apart, since their first run only records a
+// sample.
 func (job *Job) Run(transport Transport) Result {
+	result, state := job.RunWithState(transport, State{})
+
+	_, stateful := job.Agent.(StatefulAgent)
+	if !stateful || result.Status != Ok || state.First() {
+		return result
+	}
+
+	time.Sleep(SampleInterval)
+
+	result, _ = job.RunWithState(transport, state)
+
+	return result
+}
+
+// RunWithState runs the job, passing state to stateful agents. The state
+// to remember for the next run is returned. It is the zero State for
+// agents not implementing StatefulAgent.
+func (job *Job) RunWithState(transport Transport, state State) (Result, State) {
 	start := time.Now()
 
 	request := Request{
@@ -119,10 +168,25 @@ func (job *Job) Run(transport Transport) Result {
 		request.Timeout = time.Second
 	}
 
-	result := job.Agent.Run(transport, request)
+	var result Result
+	stateful, ok := job.Agent.(StatefulAgent)
+	if ok {
+		result, state = stateful.RunWithState(transport, request, state)
+	} else {
+		result = job.Agent.Run(transport, request)
+		state = State{}
+	}
 	result.Duration = time.Now().Sub(start)
 
-	return result
+	if result.Details != nil {
+		result.Details = result.Details.limit(MaxDetailsSize)
+	}
+
+	if len(state.Data) > MaxStateSize {
+		return NewResult(Failed, nil, "%s: %d bytes", ErrorStateTooLarge.Error(), len(state.Data)), State{}
+	}
+
+	return result, state
 }
 
 func NewResult(status Status, measurements *MeasurementCollection, format string, args ...interface{}) Result {
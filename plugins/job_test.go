@@ -0,0 +1,52 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+)
+
+type (
+	// sampleAgent reports the difference to the previous run.
+	sampleAgent struct {
+		runs int
+	}
+)
+
+func (a *sampleAgent) GetInfo() HumanInfo {
+	return HumanInfo{Name: "sample"}
+}
+
+func (a *sampleAgent) Run(transport Transport, request Request) Result {
+	r, _ := a.RunWithState(transport, request, State{})
+
+	return r
+}
+
+func (a *sampleAgent) RunWithState(transport Transport, request Request, state State) (Result, State) {
+	a.runs++
+
+	next, _ := NewState(time.Now(), a.runs)
+	if state.First() {
+		return NewResult(Ok, nil, "First sample recorded"), next
+	}
+
+	return NewResult(Ok, NewMeasurementCollection("elapsed", time.Since(state.Time)), "sampled"), next
+}
+
+func TestRunSamplesTwice(t *testing.T) {
+	saved := SampleInterval
+	SampleInterval = 10 * time.Millisecond
+	defer func() { SampleInterval = saved }()
+
+	agent := &sampleAgent{}
+	job := Job{AgentId: "sample", Agent: agent}
+
+	r := job.Run(nil)
+	if agent.runs != 2 || r.Text != "sampled" {
+		t.Fatalf("Expected a second sample, got %d runs: %s", agent.runs, r.Text)
+	}
+
+	if (*r.Measurements)["elapsed"].Value < SampleInterval.Seconds() {
+		t.Errorf("Samples taken %v apart", (*r.Measurements)["elapsed"])
+	}
+}
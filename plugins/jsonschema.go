@@ -0,0 +1,161 @@
+package plugins
+
+import (
+	"reflect"
+	"sort"
+)
+
+type (
+	// Schema is a JSON Schema, or a part of one.
+	Schema map[string]interface{}
+)
+
+const (
+	SchemaDialect = "http://json-schema.org/draft-07/schema#"
+)
+
+// integerMaximum are the natural upper limits of the smaller integer
+// kinds.
+var integerMaximum = map[reflect.Kind]float64{
+	reflect.Int8:   1<<7 - 1,
+	reflect.Int16:  1<<15 - 1,
+	reflect.Int32:  1<<31 - 1,
+	reflect.Uint8:  1<<8 - 1,
+	reflect.Uint16: 1<<16 - 1,
+	reflect.Uint32: 1<<32 - 1,
+}
+
+// kinds maps type names to kinds for parameters not found by reflection.
+var kinds = map[string]reflect.Kind{
+	"bool":    reflect.Bool,
+	"string":  reflect.String,
+	"int":     reflect.Int,
+	"int8":    reflect.Int8,
+	"int16":   reflect.Int16,
+	"int32":   reflect.Int32,
+	"int64":   reflect.Int64,
+	"uint":    reflect.Uint,
+	"uint8":   reflect.Uint8,
+	"uint16":  reflect.Uint16,
+	"uint32":  reflect.Uint32,
+	"uint64":  reflect.Uint64,
+	"float32": reflect.Float32,
+	"float64": reflect.Float64,
+}
+
+// Schema returns the JSON Schema for values of the parameter.
+func (p Parameter) Schema() Schema {
+	s := Schema{}
+
+	if p.kind == reflect.Invalid {
+		p.kind = kinds[p.Type]
+	}
+
+	if p.Description != "" {
+		s["description"] = p.Description
+	}
+
+	if p.Default != nil {
+		s["default"] = p.Default
+	}
+
+	var min, max string
+
+	switch {
+	case p.Type == "enum":
+		s["type"] = "string"
+		s["enum"] = p.EnumValues
+	case p.Type == "time.Duration":
+		s["type"] = "integer"
+		s["format"] = "int64"
+		min, max = "minimum", "maximum"
+	case p.Type == "time.Time":
+		s["type"] = "string"
+		s["format"] = "date-time"
+	case p.Type == "object":
+		s = objectSchema(s, p.Properties)
+	case p.Type == "array":
+		s["type"] = "array"
+		if p.Items != nil {
+			s["items"] = p.Items.Schema()
+		}
+		min, max = "minItems", "maxItems"
+	case p.Type == "map":
+		s["type"] = "object"
+		if p.Items != nil {
+			s["additionalProperties"] = p.Items.Schema()
+		}
+		min, max = "minProperties", "maxProperties"
+	case p.kind == reflect.Bool:
+		s["type"] = "boolean"
+	case p.kind == reflect.String:
+		s["type"] = "string"
+		if p.Pattern != "" {
+			s["pattern"] = p.Pattern
+		}
+		if p.Secret {
+			s["format"] = "password"
+		}
+		min, max = "minLength", "maxLength"
+	case p.kind >= reflect.Int && p.kind <= reflect.Uint64:
+		s["type"] = "integer"
+		if p.kind >= reflect.Uint {
+			s["minimum"] = 0
+		}
+		if m, found := integerMaximum[p.kind]; found {
+			s["maximum"] = m
+		}
+		min, max = "minimum", "maximum"
+	case p.kind == reflect.Float32 || p.kind == reflect.Float64:
+		s["type"] = "number"
+		min, max = "minimum", "maximum"
+	}
+
+	if min != "" && p.Min != nil {
+		s[min] = *p.Min
+	}
+
+	if max != "" && p.Max != nil {
+		s[max] = *p.Max
+	}
+
+	return s
+}
+
+func objectSchema(s Schema, parameters []Parameter) Schema {
+	properties := Schema{}
+	required := []string{}
+
+	for _, p := range parameters {
+		properties[p.Name] = p.Schema()
+
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	sort.Strings(required)
+
+	s["type"] = "object"
+	s["properties"] = properties
+	s["additionalProperties"] = false
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
+
+// Schema returns the JSON Schema for the arguments of a plugin.
+func (d Description) Schema() Schema {
+	s := Schema{
+		"title": d.Info.Name,
+	}
+
+	if d.Info.Description != "" {
+		s["description"] = d.Info.Description
+	}
+
+	return objectSchema(s, d.Parameters)
+}
@@ -66,8 +66,8 @@ func (l *Load) Run(transport plugins.Transport, request plugins.Request) plugins
 			"load1", load1,
 			"load5", load5,
 			"load15", load15,
-			"activeTasks", activeTasks,
-			"tasks", tasks),
+			"activeTasks", plugins.Measurement{Value: float64(activeTasks), Unit: plugins.Count},
+			"tasks", plugins.Measurement{Value: float64(tasks), Unit: plugins.Count}),
 		"%.02f %.02f %.02f %d/%d", load1, load5, load15, activeTasks, tasks)
 }
 
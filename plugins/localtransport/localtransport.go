@@ -1,7 +1,8 @@
 package localtransport
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net"
 	"os"
@@ -31,20 +32,54 @@ func (l LocalTransport) GetInfo() plugins.HumanInfo {
 	}
 }
 
-func (l *LocalTransport) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
-	command := exec.Command(cmd, arguments...)
+func (l *LocalTransport) Exec(cmd plugins.Command) (*plugins.Process, error) {
+	err := cmd.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if cmd.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cmd.Timeout)
+	}
+
+	argv := cmd.Argv()
+	command := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	command.Dir = cmd.Dir
+	command.Stdin = cmd.Stdin
 
-	var out bytes.Buffer
-	command.Stdout = &out
+	// Don't wait forever for stdin or for children keeping the output
+	// open after the command exited.
+	command.WaitDelay = time.Second
 
-	stderr, err := command.StderrPipe()
+	if !cmd.Sudo && len(cmd.Env) > 0 {
+		command.Env = append(os.Environ(), cmd.Environ()...)
+	}
+
+	p := plugins.NewProcess(cmd)
+	command.Stdout = p.Stdout
+	command.Stderr = p.Stderr
+
+	err = command.Start()
 	if err != nil {
-		return nil, nil, err
+		cancel()
+		return nil, err
 	}
 
-	err = command.Run()
+	go func() {
+		defer cancel()
+
+		err := command.Wait()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("killed after %s", cmd.Timeout)
+		} else if _, ok := err.(*exec.ExitError); ok {
+			err = nil
+		}
+
+		p.Exit(command.ProcessState.ExitCode(), err)
+	}()
 
-	return &out, stderr, err
+	return p, nil
 }
 
 func (l *LocalTransport) Dial(network string, address string) (net.Conn, error) {
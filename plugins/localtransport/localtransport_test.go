@@ -0,0 +1,11 @@
+package localtransport
+
+import (
+	"testing"
+
+	"github.com/abrander/alerto/plugins/plugintest"
+)
+
+func TestLocalTransport(t *testing.T) {
+	plugintest.TestTransport(t, new(LocalTransport))
+}
@@ -1,7 +1,115 @@
 package plugins
 
-import ()
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
 
 type (
-	Measurement float64
+	// Measurement is a value measured by an agent. Values are in base
+	// units; seconds for time and bytes for sizes.
+	Measurement struct {
+		Value float64 `json:"value" bson:"value"`
+		Unit  Unit    `json:"unit,omitempty" bson:"unit,omitempty"`
+		Kind  Kind    `json:"kind,omitempty" bson:"kind,omitempty"`
+
+		// Min and Max are the bounds of the value, if known.
+		Min *float64 `json:"min,omitempty" bson:"min,omitempty"`
+		Max *float64 `json:"max,omitempty" bson:"max,omitempty"`
+	}
+
+	// Unit is the unit of a measurement. The empty Unit is used for
+	// values without a unit, like load averages.
+	Unit string
+
+	// Kind tells how a measurement behaves over time. The empty Kind
+	// is a gauge.
+	Kind string
+
+	// measurement is used for decoding without recursing into the
+	// decoders of Measurement.
+	measurement Measurement
 )
+
+const (
+	Seconds        Unit = "s"
+	Bytes          Unit = "bytes"
+	BytesPerSecond Unit = "bytes/s"
+	Percent        Unit = "%"
+	Count          Unit = "count"
+	PerSecond      Unit = "/s"
+
+	// Gauge values can go up and down.
+	Gauge Kind = "gauge"
+
+	// Counter values only increase, except when reset.
+	Counter Kind = "counter"
+)
+
+// bounded returns a copy of m bounded by min and max.
+func (m Measurement) bounded(min float64, max float64) Measurement {
+	m.Min = &min
+	m.Max = &max
+
+	return m
+}
+
+// formatBytes formats b using binary prefixes.
+func formatBytes(b float64) string {
+	prefixes := []string{"", "Ki", "Mi", "Gi", "Ti", "Pi", "Ei"}
+
+	i := 0
+	for math.Abs(b) >= 1024 && i < len(prefixes)-1 {
+		b /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%g B", b)
+	}
+
+	return fmt.Sprintf("%.1f %sB", b, prefixes[i])
+}
+
+// String formats the value for humans.
+func (m Measurement) String() string {
+	switch m.Unit {
+	case Seconds:
+		return time.Duration(m.Value * float64(time.Second)).String()
+	case Bytes:
+		return formatBytes(m.Value)
+	case BytesPerSecond:
+		return formatBytes(m.Value) + "/s"
+	case Percent:
+		return fmt.Sprintf("%.1f%%", m.Value)
+	case PerSecond:
+		return fmt.Sprintf("%g/s", m.Value)
+	}
+
+	return fmt.Sprintf("%g", m.Value)
+}
+
+// UnmarshalJSON accepts a bare number as well as an object.
+func (m *Measurement) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		*m = Measurement{}
+		return json.Unmarshal(data, &m.Value)
+	}
+
+	return json.Unmarshal(data, (*measurement)(m))
+}
+
+// SetBSON accepts a bare number, as stored before measurements had units.
+func (m *Measurement) SetBSON(raw bson.Raw) error {
+	if raw.Kind != 0x03 {
+		*m = Measurement{}
+		return raw.Unmarshal(&m.Value)
+	}
+
+	return raw.Unmarshal((*measurement)(m))
+}
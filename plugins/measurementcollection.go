@@ -1,9 +1,12 @@
 package plugins
 
 import (
-	"fmt"
+	"math"
+	"sort"
 	"time"
 
+	"gopkg.in/mgo.v2/bson"
+
 	"github.com/abrander/alerto/logger"
 )
 
@@ -11,6 +14,19 @@ type (
 	MeasurementCollection map[string]Measurement
 )
 
+var (
+	// Before measurements had units, durations were stored as bare
+	// nanoseconds. These are the keys used for them by the agents.
+	legacyDurationKeys = map[string]bool{
+		"time":            true,
+		"connectDuration": true,
+		"requestDuration": true,
+	}
+)
+
+// NewMeasurementCollection returns a collection of key/value pairs. Values
+// can be numbers, time.Duration, which is converted to seconds, or
+// Measurement.
 func NewMeasurementCollection(args ...interface{}) *MeasurementCollection {
 	c := MeasurementCollection(make(map[string]Measurement))
 
@@ -24,17 +40,19 @@ func NewMeasurementCollection(args ...interface{}) *MeasurementCollection {
 
 			switch value.(type) {
 			case int:
-				c[key] = Measurement(value.(int))
+				c[key] = Measurement{Value: float64(value.(int))}
 			case int64:
-				c[key] = Measurement(value.(int64))
+				c[key] = Measurement{Value: float64(value.(int64))}
 			case uint64:
-				c[key] = Measurement(value.(uint64))
+				c[key] = Measurement{Value: float64(value.(uint64))}
 			case float32:
-				c[key] = Measurement(value.(float32))
+				c[key] = Measurement{Value: float64(value.(float32))}
 			case float64:
-				c[key] = Measurement(value.(float64))
+				c[key] = Measurement{Value: value.(float64)}
 			case time.Duration:
-				c[key] = Measurement(value.(time.Duration).Nanoseconds())
+				c[key] = Measurement{Value: value.(time.Duration).Seconds(), Unit: Seconds}
+			case Measurement:
+				c[key] = value.(Measurement)
 			default:
 				logger.Error("plugins", "Unsupported type")
 			}
@@ -47,18 +65,64 @@ func NewMeasurementCollection(args ...interface{}) *MeasurementCollection {
 }
 
 func (c MeasurementCollection) String() string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	result := ""
-	for key, value := range c {
-		result += fmt.Sprintf("%s:%f ", key, value)
+	for _, key := range keys {
+		result += key + ":" + c[key].String() + " "
 	}
 
 	return result
 }
 
+// SetBSON converts durations stored as bare nanoseconds to seconds. Only
+// whole numbers of at least a microsecond are converted, to leave values
+// with the same keys from other agents alone.
+func (c *MeasurementCollection) SetBSON(raw bson.Raw) error {
+	raws := make(map[string]bson.Raw)
+
+	err := raw.Unmarshal(&raws)
+	if err != nil {
+		return err
+	}
+
+	*c = make(MeasurementCollection, len(raws))
+	for key, r := range raws {
+		var m Measurement
+
+		err = r.Unmarshal(&m)
+		if err != nil {
+			return err
+		}
+
+		bare := r.Kind != 0x03
+		if bare && legacyDurationKeys[key] && m.Value >= 1000 && m.Value == math.Trunc(m.Value) {
+			m = Measurement{Value: time.Duration(m.Value).Seconds(), Unit: Seconds}
+		}
+
+		(*c)[key] = m
+	}
+
+	return nil
+}
+
+func (c MeasurementCollection) Add(key string, m Measurement) {
+	c[key] = m
+}
+
 func (c MeasurementCollection) AddInt(key string, value int) {
-	c[key] = Measurement(value)
+	c[key] = Measurement{Value: float64(value)}
 }
 
 func (c MeasurementCollection) AddFloat64(key string, value float64) {
-	c[key] = Measurement(value)
+	c[key] = Measurement{Value: value}
+}
+
+// AddPercent adds a percentage between 0 and 100.
+func (c MeasurementCollection) AddPercent(key string, value float64) {
+	c[key] = Measurement{Value: value, Unit: Percent}.bounded(0, 100)
 }
@@ -0,0 +1,60 @@
+package plugins
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMeasurementCollectionLegacyBSON(t *testing.T) {
+	// Stored before measurements had units.
+	data, err := bson.Marshal(bson.M{"m": bson.M{
+		"time":            float64(250000000),
+		"connectDuration": float64(1500000),
+		"status":          float64(200),
+		"requestDuration": 0.5,
+	}})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err.Error())
+	}
+
+	var doc struct {
+		M MeasurementCollection `bson:"m"`
+	}
+
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	expected := map[string]Measurement{
+		"time":            {Value: 0.25, Unit: Seconds},
+		"connectDuration": {Value: 0.0015, Unit: Seconds},
+		"status":          {Value: 200},
+
+		// Not nanoseconds, since it's not a whole number.
+		"requestDuration": {Value: 0.5},
+	}
+
+	for key, m := range expected {
+		if doc.M[key].Value != m.Value || doc.M[key].Unit != m.Unit {
+			t.Errorf("%s: Expected %v, got %v", key, m, doc.M[key])
+		}
+	}
+
+	// Measurements with units are left alone.
+	c := NewMeasurementCollection("time", Measurement{Value: 3000, Unit: Seconds})
+	data, err = bson.Marshal(bson.M{"m": c})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err.Error())
+	}
+
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	if doc.M["time"].Value != 3000 {
+		t.Errorf("Expected 3000, got %v", doc.M["time"])
+	}
+}
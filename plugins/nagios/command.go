@@ -0,0 +1,62 @@
+package nagios
+
+import (
+	"errors"
+)
+
+var (
+	ErrorUnterminatedQuote error = errors.New("Unterminated quote in command")
+)
+
+// splitCommand splits a command line into words like a POSIX shell would,
+// honoring single quotes, double quotes and backslashes. No expansion is
+// done.
+func splitCommand(command string) ([]string, error) {
+	words := []string{}
+	word := []rune{}
+	inWord := false
+
+	runes := []rune(command)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word = append(word, runes[i])
+			inWord = true
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if r == '"' && runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				word = append(word, runes[end])
+				end++
+			}
+
+			if end >= len(runes) {
+				return nil, ErrorUnterminatedQuote
+			}
+
+			i = end
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, string(word))
+				word = word[:0]
+				inWord = false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, string(word))
+	}
+
+	return words, nil
+}
@@ -0,0 +1,44 @@
+package nagios
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		command  string
+		expected []string
+		err      error
+	}{
+		{"", []string{}, nil},
+		{"  ", []string{}, nil},
+		{"check_disk -w 10% -c 5%", []string{"check_disk", "-w", "10%", "-c", "5%"}, nil},
+		{"check_disk\t-w  10%\n", []string{"check_disk", "-w", "10%"}, nil},
+		{`check_http -u '/a path'`, []string{"check_http", "-u", "/a path"}, nil},
+		{`check_http -u "/a path"`, []string{"check_http", "-u", "/a path"}, nil},
+		{`check -a 'it"s'`, []string{"check", "-a", `it"s`}, nil},
+		{`check -a "it's"`, []string{"check", "-a", "it's"}, nil},
+		{`check -a "say \"hi\""`, []string{"check", "-a", `say "hi"`}, nil},
+		{`check -a 'no \escape'`, []string{"check", "-a", `no \escape`}, nil},
+		{`check -a a\ b`, []string{"check", "-a", "a b"}, nil},
+		{`check -a pre'quoted'post`, []string{"check", "-a", "prequotedpost"}, nil},
+		{`check -a '' -b`, []string{"check", "-a", "", "-b"}, nil},
+		{`check -a $HOME`, []string{"check", "-a", "$HOME"}, nil},
+		{`check -a 'open`, nil, ErrorUnterminatedQuote},
+		{`check -a "open\"`, nil, ErrorUnterminatedQuote},
+	}
+
+	for _, c := range cases {
+		t.Run(c.command, func(t *testing.T) {
+			words, err := splitCommand(c.command)
+			if err != c.err {
+				t.Fatalf("Expected error %v, got %v", c.err, err)
+			}
+
+			if err == nil && !reflect.DeepEqual(words, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, words)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package nagios
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func init() {
+	plugins.Register("nagios", NewNagios)
+}
+
+func NewNagios() plugins.Plugin {
+	return new(Nagios)
+}
+
+type (
+	Nagios struct {
+		Command string `json:"command" description:"The plugin command line, like /usr/lib/nagios/plugins/check_disk -w 10% -c 5%" required:"true"`
+	}
+)
+
+// Nagios plugin return codes.
+const (
+	stateOk       = 0
+	stateWarning  = 1
+	stateCritical = 2
+	stateUnknown  = 3
+)
+
+var (
+	stateNames = map[int]string{
+		stateOk:       "OK",
+		stateWarning:  "WARNING",
+		stateCritical: "CRITICAL",
+		stateUnknown:  "UNKNOWN",
+	}
+)
+
+func (n Nagios) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{
+		Name:        "Nagios plugin",
+		Description: "Run a Nagios compatible check plugin",
+	}
+}
+
+// Privileged is true, as the command line can run anything on the host.
+func (n *Nagios) Privileged() bool {
+	return true
+}
+
+// status maps a plugin return code to a status. Only OK is Ok; Alerto has
+// no notion of warnings.
+func status(code int) plugins.Status {
+	if code == stateOk {
+		return plugins.Ok
+	}
+
+	return plugins.Failed
+}
+
+func (n *Nagios) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
+	args, err := splitCommand(n.Command)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error())
+	}
+
+	if len(args) == 0 {
+		return plugins.NewResult(plugins.Failed, nil, "no command given")
+	}
+
+	r, err := plugins.Exec(transport, plugins.Command{
+		Path:    args[0],
+		Args:    args[1:],
+		Timeout: request.Timeout,
+	})
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error())
+	}
+
+	code := r.ExitCode
+
+	name, found := stateNames[code]
+	if !found {
+		// Anything else is treated as UNKNOWN by Nagios.
+		name = stateNames[stateUnknown]
+	}
+
+	out := parseOutput(string(r.Stdout))
+
+	text := out.text
+	if text == "" {
+		text = fmt.Sprintf("%s (exit code %d)", name, code)
+	} else if !strings.Contains(text, name) {
+		text = name + ": " + text
+	}
+
+	result := plugins.NewResult(status(code), out.measurements, "%s", text)
+	if len(out.long) > 0 {
+		result.Details = plugins.Details{"output": out.long}
+	}
+
+	return result
+}
+
+// Ensure compliance
+var _ plugins.Agent = (*Nagios)(nil)
@@ -0,0 +1,196 @@
+package nagios
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// output is the parsed output of a plugin. The first line is the text,
+	// following lines are long output. Performance data follows a "|" on
+	// the first line and on the long output lines from the first "|".
+	output struct {
+		text         string
+		long         []string
+		measurements *plugins.MeasurementCollection
+	}
+
+	// uom is a unit of measurement from performance data.
+	uom struct {
+		factor float64
+		unit   plugins.Unit
+	}
+)
+
+var (
+	// uoms converts to the base units used in measurements.
+	uoms = map[string]uom{
+		"s":  {1, plugins.Seconds},
+		"ms": {1e-3, plugins.Seconds},
+		"us": {1e-6, plugins.Seconds},
+		"ns": {1e-9, plugins.Seconds},
+		"%":  {1, plugins.Percent},
+		"B":  {1, plugins.Bytes},
+		"KB": {1 << 10, plugins.Bytes},
+		"MB": {1 << 20, plugins.Bytes},
+		"GB": {1 << 30, plugins.Bytes},
+		"TB": {1 << 40, plugins.Bytes},
+		"c":  {1, plugins.Count},
+	}
+)
+
+func parseOutput(s string) output {
+	o := output{
+		measurements: plugins.NewMeasurementCollection(),
+	}
+
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+
+	text, perf, _ := strings.Cut(lines[0], "|")
+	o.text = strings.TrimSpace(text)
+	parsePerfData(perf, o.measurements)
+
+	inPerf := false
+	for _, line := range lines[1:] {
+		if !inPerf {
+			var found bool
+			line, perf, found = strings.Cut(line, "|")
+			if strings.TrimSpace(line) != "" {
+				o.long = append(o.long, line)
+			}
+
+			if !found {
+				continue
+			}
+
+			inPerf = true
+			line = perf
+		}
+
+		parsePerfData(line, o.measurements)
+	}
+
+	return o
+}
+
+// perfFields splits performance data on spaces outside single quotes.
+func perfFields(s string) []string {
+	fields := []string{}
+	field := ""
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			field += string(r)
+		case r == ' ' && !quoted:
+			if field != "" {
+				fields = append(fields, field)
+			}
+			field = ""
+		default:
+			field += string(r)
+		}
+	}
+
+	if field != "" {
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// parsePerfData adds the values from performance data in the format
+// 'label'=value[UOM];[warn];[crit];[min];[max] to c. Invalid and
+// undetermined values are skipped.
+func parsePerfData(s string, c *plugins.MeasurementCollection) {
+	for _, field := range perfFields(s) {
+		i := strings.LastIndex(field, "=")
+		if i < 1 {
+			continue
+		}
+
+		label := strings.Trim(field[:i], "'")
+		label = strings.Replace(label, "''", "'", -1)
+
+		values := strings.Split(field[i+1:], ";")
+
+		m, factor, err := parseValue(values[0])
+		if err != nil {
+			continue
+		}
+
+		if m.Unit == plugins.Percent {
+			min, max := 0.0, 100.0
+			m.Min, m.Max = &min, &max
+		}
+
+		if len(values) > 3 {
+			m.Min = parseBound(values[3], factor, m.Min)
+		}
+
+		if len(values) > 4 {
+			m.Max = parseBound(values[4], factor, m.Max)
+		}
+
+		c.Add(label, m)
+	}
+}
+
+// split splits s in a number and a unit of measurement.
+func split(s string) (string, string) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+' && r != 'e' && r != 'E'
+	})
+
+	if end < 0 {
+		return s, ""
+	}
+
+	return s[:end], s[end:]
+}
+
+// parseValue parses a value with an optional unit and converts it to the
+// base unit. The factor used for converting is returned as well. Values
+// in "c" are counters.
+func parseValue(s string) (plugins.Measurement, float64, error) {
+	number, unit := split(s)
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return plugins.Measurement{}, 0, fmt.Errorf("invalid value '%s'", s)
+	}
+
+	m := plugins.Measurement{Value: f}
+	factor := 1.0
+
+	u, found := uoms[unit]
+	if found {
+		factor = u.factor
+		m.Value *= factor
+		m.Unit = u.unit
+	}
+
+	if unit == "c" {
+		m.Kind = plugins.Counter
+	}
+
+	return m, factor, nil
+}
+
+// parseBound parses min or max, which are in the unit of the value. def is
+// returned if s is empty or invalid.
+func parseBound(s string, factor float64, def *float64) *float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return def
+	}
+
+	f *= factor
+
+	return &f
+}
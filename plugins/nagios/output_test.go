@@ -0,0 +1,174 @@
+package nagios
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func bound(f float64) *float64 {
+	return &f
+}
+
+func TestParseOutput(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		text     string
+		long     []string
+		measured []string
+	}{
+		{
+			name:   "text only",
+			output: "DISK OK\n",
+			text:   "DISK OK",
+		},
+		{
+			name:     "perfdata",
+			output:   "DISK OK - free space: / 3326 MB | /=2643MB;5948;5958;0;5968\n",
+			text:     "DISK OK - free space: / 3326 MB",
+			measured: []string{"/"},
+		},
+		{
+			name:   "long output",
+			output: "DISK OK\n/ 15272 MB (77%);\n/boot 68 MB (69%);\n",
+			text:   "DISK OK",
+			long:   []string{"/ 15272 MB (77%);", "/boot 68 MB (69%);"},
+		},
+		{
+			name:     "long output with perfdata",
+			output:   "DISK OK | /=2643MB;5948;5958;0;5968\n/ 15272 MB (77%);\n/boot 68 MB (69%); | /boot=68MB;88;93;0;98\n/home=69357MB;253404;253409;0;253414\n",
+			text:     "DISK OK",
+			long:     []string{"/ 15272 MB (77%);", "/boot 68 MB (69%); "},
+			measured: []string{"/", "/boot", "/home"},
+		},
+		{
+			name:   "empty",
+			output: "",
+			text:   "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := parseOutput(c.output)
+
+			if o.text != c.text {
+				t.Errorf("Expected text %q, got %q", c.text, o.text)
+			}
+
+			if !reflect.DeepEqual(o.long, c.long) {
+				t.Errorf("Expected long output %q, got %q", c.long, o.long)
+			}
+
+			if len(*o.measurements) != len(c.measured) {
+				t.Errorf("Expected %v, got %v", c.measured, *o.measurements)
+			}
+
+			for _, key := range c.measured {
+				if _, found := (*o.measurements)[key]; !found {
+					t.Errorf("Missing %s in %v", key, *o.measurements)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePerfData(t *testing.T) {
+	cases := []struct {
+		name     string
+		perf     string
+		expected map[string]plugins.Measurement
+	}{
+		{
+			name:     "plain",
+			perf:     "users=3",
+			expected: map[string]plugins.Measurement{"users": {Value: 3}},
+		},
+		{
+			name: "thresholds",
+			perf: "load1=0.5;5;10 load5=0.25;4;8;0",
+			expected: map[string]plugins.Measurement{
+				"load1": {Value: 0.5},
+				"load5": {Value: 0.25, Min: bound(0)},
+			},
+		},
+		{
+			name:     "seconds",
+			perf:     "time=0.5s;1;2;0;10",
+			expected: map[string]plugins.Measurement{"time": {Value: 0.5, Unit: plugins.Seconds, Min: bound(0), Max: bound(10)}},
+		},
+		{
+			name:     "milliseconds",
+			perf:     "rta=250ms;1000;2000;0;5000",
+			expected: map[string]plugins.Measurement{"rta": {Value: 0.25, Unit: plugins.Seconds, Min: bound(0), Max: bound(5)}},
+		},
+		{
+			name:     "microseconds",
+			perf:     "t=500us",
+			expected: map[string]plugins.Measurement{"t": {Value: 500e-6, Unit: plugins.Seconds}},
+		},
+		{
+			name:     "percent",
+			perf:     "pl=5%;20;60",
+			expected: map[string]plugins.Measurement{"pl": {Value: 5, Unit: plugins.Percent, Min: bound(0), Max: bound(100)}},
+		},
+		{
+			name:     "percent with max",
+			perf:     "pl=5%;20;60;0;50",
+			expected: map[string]plugins.Measurement{"pl": {Value: 5, Unit: plugins.Percent, Min: bound(0), Max: bound(50)}},
+		},
+		{
+			name:     "kilobytes",
+			perf:     "mem=2KB;;;0;4",
+			expected: map[string]plugins.Measurement{"mem": {Value: 2048, Unit: plugins.Bytes, Min: bound(0), Max: bound(4096)}},
+		},
+		{
+			name:     "megabytes",
+			perf:     "/=2643MB;5948;5958;0;5968",
+			expected: map[string]plugins.Measurement{"/": {Value: 2643 << 20, Unit: plugins.Bytes, Min: bound(0), Max: bound(5968 << 20)}},
+		},
+		{
+			name:     "counter",
+			perf:     "packets=1234c",
+			expected: map[string]plugins.Measurement{"packets": {Value: 1234, Unit: plugins.Count, Kind: plugins.Counter}},
+		},
+		{
+			name:     "unknown unit",
+			perf:     "temp=21.5C",
+			expected: map[string]plugins.Measurement{"temp": {Value: 21.5}},
+		},
+		{
+			name:     "quoted label",
+			perf:     "'free space'=10MB 'it''s'=1",
+			expected: map[string]plugins.Measurement{"free space": {Value: 10 << 20, Unit: plugins.Bytes}, "it's": {Value: 1}},
+		},
+		{
+			name:     "label with equals",
+			perf:     "'a=b'=1",
+			expected: map[string]plugins.Measurement{"a=b": {Value: 1}},
+		},
+		{
+			name:     "invalid bounds",
+			perf:     "time=1s;;;x;y",
+			expected: map[string]plugins.Measurement{"time": {Value: 1, Unit: plugins.Seconds}},
+		},
+		{
+			name:     "undetermined and invalid",
+			perf:     "a=U b=x =3 c= d=4",
+			expected: map[string]plugins.Measurement{"d": {Value: 4}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := plugins.NewMeasurementCollection()
+			parsePerfData(c.perf, m)
+
+			if !reflect.DeepEqual(map[string]plugins.Measurement(*m), c.expected) {
+				t.Errorf("Expected %+v, got %+v", c.expected, *m)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package netdev
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func init() {
+	plugins.Register("netdev", NewNetdev)
+}
+
+func NewNetdev() plugins.Plugin {
+	return new(Netdev)
+}
+
+type (
+	Netdev struct {
+		Interface string `json:"interface" description:"The network interface, like eth0" required:"true"`
+	}
+)
+
+var (
+	// The columns of /proc/net/dev we care about, by index.
+	columns = map[int]string{
+		0:  "rxBytes",
+		1:  "rxPackets",
+		2:  "rxErrors",
+		3:  "rxDropped",
+		8:  "txBytes",
+		9:  "txPackets",
+		10: "txErrors",
+		11: "txDropped",
+	}
+)
+
+func (n Netdev) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{
+		Name:        "Network interface",
+		Description: "Read traffic per second on a network interface",
+	}
+}
+
+func (n *Netdev) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
+	r, _ := n.RunWithState(transport, request, plugins.State{})
+
+	return r
+}
+
+// readCounters reads the counters of n.Interface from /proc/net/dev.
+func (n *Netdev) readCounters(transport plugins.Transport) (plugins.Counters, error) {
+	file, err := transport.ReadFile("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		sep := strings.Index(scanner.Text(), ":")
+		if sep < 0 || strings.TrimSpace(scanner.Text()[:sep]) != n.Interface {
+			continue
+		}
+
+		data := strings.Fields(scanner.Text()[sep+1:])
+
+		counters := make(plugins.Counters)
+		for i, key := range columns {
+			if i >= len(data) {
+				continue
+			}
+
+			counters[key], err = strconv.ParseUint(data[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return counters, nil
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	return nil, fmt.Errorf("interface %s not found", n.Interface)
+}
+
+// RunWithState calculates rates from the difference between the counters
+// read now and the counters read by the previous run. The first run, and
+// the first run after the counters are reset, only records the counters.
+func (n *Netdev) RunWithState(transport plugins.Transport, request plugins.Request, state plugins.State) (plugins.Result, plugins.State) {
+	now := time.Now()
+
+	counters, err := n.readCounters(transport)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error()), state
+	}
+
+	next, err := plugins.NewState(now, counters)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", err.Error()), state
+	}
+
+	var previous plugins.Counters
+	if state.First() || state.Decode(&previous) != nil {
+		return plugins.NewResult(plugins.Ok, nil, "First sample recorded"), next
+	}
+
+	rates, reset := counters.Rates(previous, now.Sub(state.Time))
+	if reset {
+		return plugins.NewResult(plugins.Ok, nil, "Counters reset, sample recorded"), next
+	}
+
+	m := plugins.NewMeasurementCollection()
+	for key, value := range rates {
+		unit := plugins.PerSecond
+		if strings.HasSuffix(key, "Bytes") {
+			unit = plugins.BytesPerSecond
+		}
+
+		m.Add(key, plugins.Measurement{Value: value, Unit: unit})
+	}
+
+	return plugins.NewResult(plugins.Ok, m, "rx %.0f B/s, tx %.0f B/s", rates["rxBytes"], rates["txBytes"]), next
+}
+
+// Ensure compliance
+var _ plugins.StatefulAgent = (*Netdev)(nil)
@@ -1,7 +1,6 @@
 package noop
 
 import (
-	"bytes"
 	"io"
 	"net"
 	"time"
@@ -19,7 +18,7 @@ func NewNoop() plugins.Plugin {
 
 type (
 	Noop struct {
-		Delay time.Duration `json:"delay" description:"Amount of time to do nothing"`
+		Delay time.Duration `json:"delay" description:"Amount of time to do nothing" max:"1h"`
 	}
 )
 
@@ -35,9 +34,11 @@ func (n *Noop) Run(transport plugins.Transport, request plugins.Request) plugins
 	return plugins.NewResult(plugins.Ok, nil, "noop ;-)")
 }
 
-func (n *Noop) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
-	var stdoutBuf, stderrBuf bytes.Buffer
-	return &stdoutBuf, &stderrBuf, nil
+func (n *Noop) Exec(cmd plugins.Command) (*plugins.Process, error) {
+	p := plugins.NewProcess(cmd)
+	p.Exit(0, nil)
+
+	return p, nil
 }
 
 func (n *Noop) Dial(network string, address string) (net.Conn, error) {
@@ -1,7 +1,6 @@
 package pidof
 
 import (
-	"io/ioutil"
 	"strings"
 
 	"github.com/abrander/alerto/plugins"
@@ -17,7 +16,7 @@ func NewPidOf() plugins.Plugin {
 
 type (
 	PidOf struct {
-		ProcessName string `json:"processName" description:"The processname to look up"`
+		ProcessName string `json:"processName" description:"The processname to look up" required:"true"`
 	}
 )
 
@@ -29,23 +28,27 @@ func (p PidOf) GetInfo() plugins.HumanInfo {
 }
 
 func (p *PidOf) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
-	stdout, _, err := transport.Exec("/bin/pidof", p.ProcessName)
+	r, err := plugins.Exec(transport, plugins.Command{
+		Path:    "/bin/pidof",
+		Args:    []string{p.ProcessName},
+		Timeout: request.Timeout,
+	})
 	if err != nil {
 		return plugins.NewResult(plugins.Failed, nil, err.Error())
 	}
 
-	content, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		return plugins.NewResult(plugins.Failed, nil, err.Error())
+	if r.ExitCode != 0 {
+		return plugins.NewResult(plugins.Failed, nil, "%s is not running", p.ProcessName)
 	}
 
+	content := r.Stdout
 	fields := strings.Fields(string(content))
 
 	return plugins.NewResult(
 		plugins.Ok,
 		plugins.NewMeasurementCollection(
 			"count",
-			len(fields)),
+			plugins.Measurement{Value: float64(len(fields)), Unit: plugins.Count}),
 		"%s has PID(s) %s", p.ProcessName, strings.TrimSpace(string(content)))
 }
 
@@ -4,7 +4,6 @@ import (
 	"io"
 	"net"
 	"reflect"
-	"strings"
 	"time"
 
 	"github.com/abrander/alerto/logger"
@@ -20,9 +19,41 @@ type (
 		Run(Transport, Request) Result
 	}
 
+	// StatefulAgent is implemented by agents remembering something between
+	// runs, like counters used for calculating rates. RunWithState is
+	// called instead of Run, and the returned state is passed to the next
+	// run for the same monitor.
+	StatefulAgent interface {
+		Agent
+		RunWithState(Transport, Request, State) (Result, State)
+	}
+
+	// Describer is implemented by plugins describing their own
+	// parameters, instead of having them found by reflection.
+	Describer interface {
+		Describe() Description
+	}
+
+	// Validator is implemented by plugins validating their own
+	// parameters.
+	Validator interface {
+		Validate() error
+	}
+
+	// Defaulter is implemented by plugins applying their own defaults.
+	Defaulter interface {
+		ApplyDefaults()
+	}
+
+	// Privileged is implemented by agents running commands or code given
+	// in their arguments. Only admins can configure them.
+	Privileged interface {
+		Privileged() bool
+	}
+
 	Transport interface {
 		Plugin
-		Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error)
+		Exec(Command) (*Process, error)
 		Dial(network string, address string) (net.Conn, error)
 		ReadFile(path string) (io.Reader, error)
 	}
@@ -36,6 +67,10 @@ type (
 		Text         string
 		Duration     time.Duration
 		Measurements *MeasurementCollection
+
+		// Details is optional structured data, limited to
+		// MaxDetailsSize.
+		Details Details `json:",omitempty" bson:",omitempty"`
 	}
 
 	HumanInfo struct {
@@ -46,13 +81,36 @@ type (
 	Description struct {
 		Parameters []Parameter `json:"parameters"`
 		Info       HumanInfo   `json:"info"`
+		Privileged bool        `json:"privileged,omitempty"`
 	}
 
+	// Parameter describes a field of a plugin. Type is the Go type for
+	// scalars, "enum" for strings with EnumValues and "object", "array" or
+	// "map" for composite types.
 	Parameter struct {
-		Name        string   `json:"name"`
-		Type        string   `json:"type"`
-		Description string   `json:"description"`
-		EnumValues  []string `json:"enumValues"`
+		Name        string      `json:"name"`
+		Type        string      `json:"type"`
+		Description string      `json:"description"`
+		EnumValues  []string    `json:"enumValues"`
+		Default     interface{} `json:"default,omitempty"`
+		Required    bool        `json:"required"`
+
+		// Min and Max limit numbers, and the length of strings, arrays and
+		// maps.
+		Min     *float64 `json:"min,omitempty"`
+		Max     *float64 `json:"max,omitempty"`
+		Pattern string   `json:"pattern,omitempty"`
+
+		// Secret parameters should not be shown in clear text.
+		Secret bool `json:"secret,omitempty"`
+
+		// Items describes the elements of arrays and the values of maps.
+		Items *Parameter `json:"items,omitempty"`
+
+		// Properties describes the fields of objects.
+		Properties []Parameter `json:"properties,omitempty"`
+
+		kind reflect.Kind
 	}
 
 	Constructor func() Plugin
@@ -77,39 +135,6 @@ func Register(protocol string, constructor Constructor) {
 	plugins[protocol] = constructor
 }
 
-func getParams(elem reflect.Type) []Parameter {
-	parameters := []Parameter{}
-
-	l := elem.NumField()
-
-	for i := 0; i < l; i++ {
-		f := elem.Field(i)
-
-		jsonName := f.Tag.Get("json")
-
-		if f.Anonymous {
-			parameters = append(parameters, getParams(f.Type)...)
-		} else if jsonName != "" {
-			p := Parameter{}
-
-			p.Name = jsonName
-			p.Type = f.Type.String()
-			p.Description = f.Tag.Get("description")
-			enum := f.Tag.Get("enum")
-			if enum != "" {
-				p.EnumValues = strings.Split(enum, ",")
-				p.Type = "enum"
-			} else {
-				p.EnumValues = []string{}
-			}
-
-			parameters = append(parameters, p)
-		}
-	}
-
-	return parameters
-}
-
 func getDescription(elem reflect.Type) Description {
 	pl := reflect.Zero(elem).Interface().(Plugin)
 	return Description{
@@ -128,11 +153,24 @@ func getPlugins(iType reflect.Type) map[string]Description {
 	r := make(map[string]Description)
 
 	for name, plugin := range plugins {
-		pType := reflect.TypeOf(plugin())
-		elem := reflect.TypeOf(plugin()).Elem()
-		if pType.Implements(iType) {
-			r[name] = getDescription(elem)
+		p := plugin()
+		pType := reflect.TypeOf(p)
+		if !pType.Implements(iType) {
+			continue
+		}
+
+		var desc Description
+		if d, ok := p.(Describer); ok {
+			desc = d.Describe()
+		} else {
+			desc = getDescription(pType.Elem())
+		}
+
+		if pr, ok := p.(Privileged); ok {
+			desc.Privileged = pr.Privileged()
 		}
+
+		r[name] = desc
 	}
 
 	return r
@@ -0,0 +1,202 @@
+package plugintest
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	execCase struct {
+		name   string
+		cmd    plugins.Command
+		stdout string
+		stderr string
+		code   int
+	}
+)
+
+var (
+	// awkward arguments must survive every transport unchanged.
+	awkward = []string{
+		"plain",
+		"with space",
+		"",
+		"'single'",
+		`"double"`,
+		`back\slash`,
+		"$HOME",
+		"`id`",
+		"$(id)",
+		"semi;colon",
+		"pipe|and&",
+		"new\nline",
+		"*",
+		"-n",
+	}
+)
+
+func execCases() []execCase {
+	return []execCase{
+		{
+			name:   "arguments",
+			cmd:    plugins.Command{Path: "printf", Args: append([]string{"[%s]"}, awkward...)},
+			stdout: "[" + strings.Join(awkward, "][") + "]",
+		},
+		{
+			name: "exit code",
+			cmd:  plugins.Command{Path: "sh", Args: []string{"-c", "exit 3"}},
+			code: 3,
+		},
+		{
+			name:   "stderr",
+			cmd:    plugins.Command{Path: "sh", Args: []string{"-c", "printf out; printf err >&2"}},
+			stdout: "out",
+			stderr: "err",
+		},
+		{
+			name:   "stdin",
+			cmd:    plugins.Command{Path: "cat", Stdin: strings.NewReader("from stdin")},
+			stdout: "from stdin",
+		},
+		{
+			name:   "environment",
+			cmd:    plugins.Command{Path: "sh", Args: []string{"-c", `printf %s "$ALERTO_TEST"`}, Env: map[string]string{"ALERTO_TEST": "it's $set"}},
+			stdout: "it's $set",
+		},
+		{
+			name:   "working directory",
+			cmd:    plugins.Command{Path: "pwd", Dir: "/"},
+			stdout: "/\n",
+		},
+		{
+			name:   "stdout limit",
+			cmd:    plugins.Command{Path: "printf", Args: []string{"0123456789"}, StdoutLimit: 4},
+			stdout: "0123",
+		},
+	}
+}
+
+// TestTransport checks that transport behaves like the other transports.
+// The host reached by the transport must be a POSIX system with sh,
+// printf, cat, pwd, sleep and env. Use it from a test like:
+//
+//	func TestTransport(t *testing.T) {
+//		plugintest.TestTransport(t, new(LocalTransport))
+//	}
+func TestTransport(t *testing.T, transport plugins.Transport) {
+	t.Run("Exec", func(t *testing.T) {
+		testExec(t, transport)
+	})
+
+	t.Run("ReadFile", func(t *testing.T) {
+		testReadFile(t, transport)
+	})
+
+	t.Run("Dial", func(t *testing.T) {
+		testDial(t, transport)
+	})
+}
+
+// testExec runs commands through transport and checks the output and exit
+// codes.
+func testExec(t *testing.T, transport plugins.Transport) {
+	for _, c := range execCases() {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := plugins.Exec(transport, c.cmd)
+			if err != nil {
+				t.Fatalf("Exec() returned %s", err.Error())
+			}
+
+			if string(r.Stdout) != c.stdout {
+				t.Errorf("got stdout %q, expected %q", r.Stdout, c.stdout)
+			}
+
+			if string(r.Stderr) != c.stderr {
+				t.Errorf("got stderr %q, expected %q", r.Stderr, c.stderr)
+			}
+
+			if r.ExitCode != c.code {
+				t.Errorf("got exit code %d, expected %d", r.ExitCode, c.code)
+			}
+
+			limited := c.cmd.StdoutLimit > 0 && int64(len(c.stdout)) == c.cmd.StdoutLimit
+			if r.Truncated != limited {
+				t.Errorf("got truncated %v, expected %v", r.Truncated, limited)
+			}
+		})
+	}
+
+	t.Run("invalid environment", func(t *testing.T) {
+		_, err := transport.Exec(plugins.Command{Path: "true", Env: map[string]string{"A=B": "c"}})
+		if err == nil {
+			t.Errorf("Exec() accepted an invalid variable name")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := plugins.Exec(transport, plugins.Command{Path: "sleep", Args: []string{"10"}, Timeout: 100 * time.Millisecond})
+		if err == nil {
+			t.Errorf("Exec() did not return an error on timeout")
+		}
+
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("Exec() took %s", time.Since(start))
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		p, err := transport.Exec(plugins.Command{Path: "sh", Args: []string{"-c", "echo first; read line; echo $line"}, Stdin: strings.NewReader("second\n")})
+		if err != nil {
+			t.Fatalf("Exec() returned %s", err.Error())
+		}
+
+		buf := make([]byte, 6)
+		n, _ := p.Stdout.Read(buf)
+		if string(buf[:n]) != "first\n" {
+			t.Errorf("got %q before exit, expected %q", buf[:n], "first\n")
+		}
+
+		code, err := p.Wait()
+		if code != 0 || err != nil {
+			t.Errorf("Wait() returned %d, %v", code, err)
+		}
+	})
+}
+
+// testReadFile reads a file known to exist on every host.
+func testReadFile(t *testing.T, transport plugins.Transport) {
+	r, err := transport.ReadFile("/etc/passwd")
+	if err != nil {
+		t.Fatalf("ReadFile() returned %s", err.Error())
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil || !strings.Contains(string(content), "root:") {
+		t.Errorf("ReadFile() returned %q, %v", content, err)
+	}
+
+	_, err = transport.ReadFile("/nonexistent")
+	if err == nil {
+		t.Errorf("ReadFile() of a missing file returned no error")
+	}
+}
+
+// testDial expects errors when dialing closed ports and unknown networks.
+func testDial(t *testing.T, transport plugins.Transport) {
+	conn, err := transport.Dial("tcp", "127.0.0.1:1")
+	if err == nil {
+		conn.Close()
+		t.Errorf("Dial() of a closed port returned no error")
+	}
+
+	conn, err = transport.Dial("nonsense", "127.0.0.1:1")
+	if err == nil {
+		conn.Close()
+		t.Errorf("Dial() of an unknown network returned no error")
+	}
+}
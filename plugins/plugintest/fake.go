@@ -0,0 +1,254 @@
+// Package plugintest helps testing agents and transports without real
+// hosts. Fake is a scriptable transport, Recorder records the interactions
+// with a real transport for replaying them with a Fake later, and
+// TestTransport is a conformance suite for transports.
+package plugintest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Fake is a transport returning canned data. Commands are identified
+	// by their arguments as returned by plugins.Command.Argv.
+	Fake struct {
+		lock     sync.Mutex
+		files    map[string]File
+		commands map[string]CommandFunc
+		conns    map[string]ConnFunc
+		calls    []Call
+	}
+
+	// File is the content of a file, or the error returned when reading
+	// it.
+	File struct {
+		Content string `json:"content"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	// Reply is the outcome of a command. Error is returned if the command
+	// could not be executed at all. The command exits after Duration, or
+	// is killed if that is longer than its timeout.
+	Reply struct {
+		Stdout   string        `json:"stdout"`
+		Stderr   string        `json:"stderr"`
+		ExitCode int           `json:"exitCode"`
+		Error    string        `json:"error,omitempty"`
+		Duration time.Duration `json:"duration,omitempty"`
+
+		// StdoutTruncated and StderrTruncated mark output as truncated
+		// even if it fits the limits of the command.
+		StdoutTruncated bool `json:"stdoutTruncated,omitempty"`
+		StderrTruncated bool `json:"stderrTruncated,omitempty"`
+	}
+
+	// CommandFunc replies to a command.
+	CommandFunc func(cmd plugins.Command) Reply
+
+	// ConnFunc is the remote end of a connection. The connection is
+	// closed when it returns.
+	ConnFunc func(conn net.Conn)
+
+	// Call is a call made to a Fake. Target is the path of a file, the
+	// network and address of a connection separated by a space, or the
+	// arguments of a command encoded as JSON.
+	Call struct {
+		Method string
+		Target string
+	}
+)
+
+var (
+	ErrorNotFaked error = errors.New("Not faked")
+)
+
+func NewFake() *Fake {
+	return &Fake{
+		files:    make(map[string]File),
+		commands: make(map[string]CommandFunc),
+		conns:    make(map[string]ConnFunc),
+	}
+}
+
+func (f *Fake) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{
+		Name:        "Fake",
+		Description: "Canned data for tests",
+	}
+}
+
+// AddFile adds a file with content.
+func (f *Fake) AddFile(path string, content string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.files[path] = File{Content: content}
+}
+
+// commandKey identifies a command by its arguments. JSON keeps
+// ["a b"] and ["a", "b"] apart.
+func commandKey(argv []string) string {
+	key, _ := json.Marshal(argv)
+
+	return string(key)
+}
+
+// AddCommand adds a command always replying with reply. argv is the
+// command as returned by plugins.Command.Argv, like
+// []string{"cat", "/proc/loadavg"}.
+func (f *Fake) AddCommand(argv []string, reply Reply) {
+	f.HandleCommand(argv, func(cmd plugins.Command) Reply {
+		return reply
+	})
+}
+
+// HandleCommand adds a command replying by calling fn, for commands
+// depending on stdin or the environment.
+func (f *Fake) HandleCommand(argv []string, fn CommandFunc) {
+	f.handleCommand(commandKey(argv), fn)
+}
+
+func (f *Fake) handleCommand(key string, fn CommandFunc) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.commands[key] = fn
+}
+
+// AddConn makes Dial connect to fn.
+func (f *Fake) AddConn(network string, address string, fn ConnFunc) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.conns[network+" "+address] = fn
+}
+
+// AddConnReply makes Dial connect to a remote end sending reply, ignoring
+// anything sent to it.
+func (f *Fake) AddConnReply(network string, address string, reply string) {
+	f.AddConn(network, address, func(conn net.Conn) {
+		go io.Copy(ioutil.Discard, conn)
+
+		io.WriteString(conn, reply)
+	})
+}
+
+// Calls returns the calls made to f, in order.
+func (f *Fake) Calls() []Call {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+func (f *Fake) call(method string, target string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.calls = append(f.calls, Call{Method: method, Target: target})
+}
+
+func (f *Fake) Exec(cmd plugins.Command) (*plugins.Process, error) {
+	err := cmd.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	key := commandKey(cmd.Argv())
+	f.call("Exec", key)
+
+	f.lock.Lock()
+	fn, found := f.commands[key]
+	f.lock.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotFaked.Error(), key)
+	}
+
+	reply := fn(cmd)
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	p := plugins.NewProcess(cmd)
+	io.WriteString(p.Stdout, reply.Stdout)
+	io.WriteString(p.Stderr, reply.Stderr)
+
+	if reply.StdoutTruncated {
+		p.Stdout.SetTruncated()
+	}
+
+	if reply.StderrTruncated {
+		p.Stderr.SetTruncated()
+	}
+
+	if reply.Duration <= 0 {
+		p.Exit(reply.ExitCode, nil)
+		return p, nil
+	}
+
+	go func() {
+		if cmd.Timeout > 0 && reply.Duration >= cmd.Timeout {
+			time.Sleep(cmd.Timeout)
+			p.Exit(-1, fmt.Errorf("killed after %s", cmd.Timeout))
+			return
+		}
+
+		time.Sleep(reply.Duration)
+		p.Exit(reply.ExitCode, nil)
+	}()
+
+	return p, nil
+}
+
+func (f *Fake) Dial(network string, address string) (net.Conn, error) {
+	key := network + " " + address
+	f.call("Dial", key)
+
+	f.lock.Lock()
+	fn, found := f.conns[key]
+	f.lock.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotFaked.Error(), key)
+	}
+
+	client, server := net.Pipe()
+	go func() {
+		fn(server)
+		server.Close()
+	}()
+
+	return client, nil
+}
+
+func (f *Fake) ReadFile(path string) (io.Reader, error) {
+	f.call("ReadFile", path)
+
+	f.lock.Lock()
+	file, found := f.files[path]
+	f.lock.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotFaked.Error(), path)
+	}
+
+	if file.Error != "" {
+		return nil, errors.New(file.Error)
+	}
+
+	return strings.NewReader(file.Content), nil
+}
+
+// Ensure compliance
+var _ plugins.Transport = (*Fake)(nil)
@@ -0,0 +1,126 @@
+package plugintest
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func TestFakeExec(t *testing.T) {
+	f := NewFake()
+	f.AddCommand([]string{"echo", "a b"}, Reply{Stdout: "one argument"})
+	f.AddCommand([]string{"echo", "a", "b"}, Reply{Stdout: "two arguments", ExitCode: 2})
+	f.AddCommand([]string{"broken"}, Reply{Error: "no such file"})
+	f.HandleCommand([]string{"cat"}, func(cmd plugins.Command) Reply {
+		stdin, _ := ioutil.ReadAll(cmd.Stdin)
+		return Reply{Stdout: string(stdin) + cmd.Env["SUFFIX"]}
+	})
+
+	cases := []struct {
+		cmd    plugins.Command
+		stdout string
+		code   int
+	}{
+		{plugins.Command{Path: "echo", Args: []string{"a b"}}, "one argument", 0},
+		{plugins.Command{Path: "echo", Args: []string{"a", "b"}}, "two arguments", 2},
+		{plugins.Command{Path: "cat", Stdin: strings.NewReader("in"), Env: map[string]string{"SUFFIX": "put"}}, "input", 0},
+	}
+
+	for _, c := range cases {
+		r, err := plugins.Exec(f, c.cmd)
+		if err != nil {
+			t.Fatalf("%v: Exec() returned %s", c.cmd.Argv(), err.Error())
+		}
+
+		if string(r.Stdout) != c.stdout || r.ExitCode != c.code {
+			t.Errorf("%v: Expected %q and %d, got %q and %d", c.cmd.Argv(), c.stdout, c.code, r.Stdout, r.ExitCode)
+		}
+	}
+
+	_, err := f.Exec(plugins.Command{Path: "broken"})
+	if err == nil || err.Error() != "no such file" {
+		t.Errorf("Expected error from reply, got %v", err)
+	}
+
+	_, err = f.Exec(plugins.Command{Path: "echo", Args: []string{"a"}})
+	if err == nil || !strings.HasPrefix(err.Error(), ErrorNotFaked.Error()) {
+		t.Errorf("Expected %s, got %v", ErrorNotFaked.Error(), err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 5 || calls[0].Method != "Exec" || calls[0].Target != `["echo","a b"]` {
+		t.Errorf("Wrong calls %v", calls)
+	}
+}
+
+func TestFakeDuration(t *testing.T) {
+	f := NewFake()
+	f.AddCommand([]string{"slow"}, Reply{Stdout: "done", Duration: 50 * time.Millisecond})
+
+	start := time.Now()
+	r, err := plugins.Exec(f, plugins.Command{Path: "slow"})
+	if err != nil || string(r.Stdout) != "done" {
+		t.Errorf("Exec() returned %q, %v", r.Stdout, err)
+	}
+
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Command exited after %s", time.Since(start))
+	}
+
+	_, err = plugins.Exec(f, plugins.Command{Path: "slow", Timeout: 10 * time.Millisecond})
+	if err == nil {
+		t.Errorf("Command was not killed")
+	}
+}
+
+func TestFakeReadFile(t *testing.T) {
+	f := NewFake()
+	f.AddFile("/proc/loadavg", "0.10 0.20 0.30 1/100 1234\n")
+
+	r, err := f.ReadFile("/proc/loadavg")
+	if err != nil {
+		t.Fatalf("ReadFile() returned %s", err.Error())
+	}
+
+	content, _ := ioutil.ReadAll(r)
+	if string(content) != "0.10 0.20 0.30 1/100 1234\n" {
+		t.Errorf("Wrong content %q", content)
+	}
+
+	_, err = f.ReadFile("/etc/passwd")
+	if err == nil {
+		t.Errorf("ReadFile() of a file not faked returned no error")
+	}
+}
+
+func TestFakeDial(t *testing.T) {
+	f := NewFake()
+	f.AddConnReply("tcp", "example.com:25", "220 example.com ESMTP\r\n")
+
+	conn, err := f.Dial("tcp", "example.com:25")
+	if err != nil {
+		t.Fatalf("Dial() returned %s", err.Error())
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte("EHLO alerto\r\n"))
+	if err != nil {
+		t.Errorf("Write() returned %s", err.Error())
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "220 example.com ESMTP\r\n" {
+		t.Errorf("Read %q, %v", line, err)
+	}
+
+	_, err = f.Dial("udp", "example.com:25")
+	if err == nil {
+		t.Errorf("Dial() of a connection not faked returned no error")
+	}
+}
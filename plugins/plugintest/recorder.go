@@ -0,0 +1,267 @@
+package plugintest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Fixture is recorded interactions with a transport, keyed like the
+	// canned data of a Fake.
+	Fixture struct {
+		Files    map[string]File     `json:"files"`
+		Commands map[string]Reply    `json:"commands"`
+		Conns    map[string]Exchange `json:"conns"`
+	}
+
+	// Exchange is the data sent and received on a connection.
+	Exchange struct {
+		Sent     string `json:"sent"`
+		Received string `json:"received"`
+	}
+
+	// Recorder is a transport recording the interactions with another
+	// transport.
+	Recorder struct {
+		transport plugins.Transport
+
+		lock     sync.Mutex
+		files    map[string]File
+		commands map[string]Reply
+		conns    map[string]*recordingConn
+	}
+
+	recordingConn struct {
+		net.Conn
+
+		lock     sync.Mutex
+		sent     bytes.Buffer
+		received bytes.Buffer
+	}
+)
+
+// NewRecorder returns a recorder passing everything on to transport.
+func NewRecorder(transport plugins.Transport) *Recorder {
+	return &Recorder{
+		transport: transport,
+		files:     make(map[string]File),
+		commands:  make(map[string]Reply),
+		conns:     make(map[string]*recordingConn),
+	}
+}
+
+func (r *Recorder) GetInfo() plugins.HumanInfo {
+	return r.transport.GetInfo()
+}
+
+func (r *Recorder) Exec(cmd plugins.Command) (*plugins.Process, error) {
+	key := commandKey(cmd.Argv())
+	start := time.Now()
+
+	p, err := r.transport.Exec(cmd)
+	if err != nil {
+		r.lock.Lock()
+		r.commands[key] = Reply{Error: err.Error()}
+		r.lock.Unlock()
+
+		return nil, err
+	}
+
+	// The output is passed on while it's recorded, to keep the process
+	// streaming.
+	recorded := plugins.NewProcess(cmd)
+
+	go func() {
+		var stdout, stderr bytes.Buffer
+		var wg sync.WaitGroup
+
+		wg.Add(2)
+		go func() {
+			io.Copy(io.MultiWriter(recorded.Stdout, &stdout), p.Stdout)
+			wg.Done()
+		}()
+		go func() {
+			io.Copy(io.MultiWriter(recorded.Stderr, &stderr), p.Stderr)
+			wg.Done()
+		}()
+		wg.Wait()
+
+		if p.Stdout.Truncated() {
+			recorded.Stdout.SetTruncated()
+		}
+
+		if p.Stderr.Truncated() {
+			recorded.Stderr.SetTruncated()
+		}
+
+		code, err := p.Wait()
+
+		r.lock.Lock()
+		r.commands[key] = Reply{
+			Stdout:   stdout.String(),
+			Stderr:   stderr.String(),
+			ExitCode: code,
+			Duration: time.Since(start),
+
+			StdoutTruncated: p.Stdout.Truncated(),
+			StderrTruncated: p.Stderr.Truncated(),
+		}
+		r.lock.Unlock()
+
+		recorded.Exit(code, err)
+	}()
+
+	return recorded, nil
+}
+
+func (r *Recorder) Dial(network string, address string) (net.Conn, error) {
+	conn, err := r.transport.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &recordingConn{Conn: conn}
+
+	r.lock.Lock()
+	r.conns[network+" "+address] = c
+	r.lock.Unlock()
+
+	return c, nil
+}
+
+func (r *Recorder) ReadFile(path string) (io.Reader, error) {
+	reader, err := r.transport.ReadFile(path)
+
+	var content []byte
+	if err == nil {
+		content, err = ioutil.ReadAll(reader)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err != nil {
+		r.files[path] = File{Error: err.Error()}
+		return nil, err
+	}
+
+	r.files[path] = File{Content: string(content)}
+
+	return bytes.NewReader(content), nil
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	c.lock.Lock()
+	c.received.Write(p[:n])
+	c.lock.Unlock()
+
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+
+	c.lock.Lock()
+	c.sent.Write(p[:n])
+	c.lock.Unlock()
+
+	return n, err
+}
+
+func (c *recordingConn) exchange() Exchange {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return Exchange{Sent: c.sent.String(), Received: c.received.String()}
+}
+
+// Fixture returns everything recorded so far. Commands still running are
+// left out. Only the latest of repeated calls is kept.
+func (r *Recorder) Fixture() Fixture {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := Fixture{
+		Files:    make(map[string]File, len(r.files)),
+		Commands: make(map[string]Reply, len(r.commands)),
+		Conns:    make(map[string]Exchange, len(r.conns)),
+	}
+
+	for path, file := range r.files {
+		f.Files[path] = file
+	}
+
+	for key, reply := range r.commands {
+		f.Commands[key] = reply
+	}
+
+	for key, c := range r.conns {
+		f.Conns[key] = c.exchange()
+	}
+
+	return f
+}
+
+// Save writes the fixture to a file.
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Fixture(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Replay returns a Fake with the canned data of f. The remote end of
+// connections sends what was received when recording, and ignores what
+// is sent to it.
+func Replay(f Fixture) *Fake {
+	fake := NewFake()
+
+	for path, file := range f.Files {
+		fake.files[path] = file
+	}
+
+	for key, reply := range f.Commands {
+		reply := reply
+		fake.handleCommand(key, func(cmd plugins.Command) Reply {
+			return reply
+		})
+	}
+
+	for key, exchange := range f.Conns {
+		network, address, _ := strings.Cut(key, " ")
+		fake.AddConnReply(network, address, exchange.Received)
+	}
+
+	return fake
+}
+
+// Load returns a Fake replaying a fixture file written by Save.
+func Load(path string) (*Fake, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixture
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	return Replay(f), nil
+}
+
+// Ensure compliance
+var _ plugins.Transport = (*Recorder)(nil)
@@ -0,0 +1,76 @@
+package plugintest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abrander/alerto/plugins"
+	"github.com/abrander/alerto/plugins/localtransport"
+)
+
+// TestRecordReplay runs the conformance suite through a Recorder, and
+// again against a Fake replaying what was recorded. This checks both the
+// round trip and that a Fake behaves like a real transport.
+func TestRecordReplay(t *testing.T) {
+	recorder := NewRecorder(new(localtransport.LocalTransport))
+
+	t.Run("Recorder", func(t *testing.T) {
+		TestTransport(t, recorder)
+	})
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	err := recorder.Save(path)
+	if err != nil {
+		t.Fatalf("Save() returned %s", err.Error())
+	}
+
+	fake, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() returned %s", err.Error())
+	}
+
+	t.Run("Fake", func(t *testing.T) {
+		TestTransport(t, fake)
+	})
+
+	for _, call := range fake.Calls() {
+		if call.Method == "Exec" {
+			return
+		}
+	}
+
+	t.Errorf("No commands replayed")
+}
+
+func TestReplayKeepsArgumentsApart(t *testing.T) {
+	fake := Replay(Fixture{
+		Commands: map[string]Reply{
+			commandKey([]string{"echo", "a b"}):    {Stdout: "one"},
+			commandKey([]string{"echo", "a", "b"}): {Stdout: "two"},
+		},
+	})
+
+	r := NewRecorder(fake)
+
+	for _, c := range []struct {
+		args   []string
+		stdout string
+	}{
+		{[]string{"a b"}, "one"},
+		{[]string{"a", "b"}, "two"},
+	} {
+		result, err := plugins.Exec(r, plugins.Command{Path: "echo", Args: c.args})
+		if err != nil {
+			t.Fatalf("Exec() returned %s", err.Error())
+		}
+
+		if string(result.Stdout) != c.stdout {
+			t.Errorf("%v: Expected %q, got %q", c.args, c.stdout, result.Stdout)
+		}
+	}
+
+	if len(r.Fixture().Commands) != 2 {
+		t.Errorf("Expected 2 recorded commands, got %v", r.Fixture().Commands)
+	}
+}
@@ -0,0 +1,506 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// ValidationError is returned when a plugin has parameters violating
+	// its description.
+	ValidationError struct {
+		Parameter string
+		Problem   string
+	}
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+
+	integerTypes = map[string]reflect.Type{
+		"int":           reflect.TypeOf(int(0)),
+		"int8":          reflect.TypeOf(int8(0)),
+		"int16":         reflect.TypeOf(int16(0)),
+		"int32":         reflect.TypeOf(int32(0)),
+		"int64":         reflect.TypeOf(int64(0)),
+		"uint":          reflect.TypeOf(uint(0)),
+		"uint8":         reflect.TypeOf(uint8(0)),
+		"uint16":        reflect.TypeOf(uint16(0)),
+		"uint32":        reflect.TypeOf(uint32(0)),
+		"uint64":        reflect.TypeOf(uint64(0)),
+		"time.Duration": durationType,
+	}
+)
+
+func (e *ValidationError) Error() string {
+	return e.Parameter + ": " + e.Problem
+}
+
+func invalid(name string, format string, args ...interface{}) error {
+	return &ValidationError{
+		Parameter: name,
+		Problem:   fmt.Sprintf(format, args...),
+	}
+}
+
+// fieldName returns the name of a struct field in JSON documents or an
+// empty string if the field is not a parameter.
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// parseLimit parses a min or max tag. Durations can be given like "1s".
+func parseLimit(p Parameter, tag string) *float64 {
+	if tag == "" {
+		return nil
+	}
+
+	var f float64
+	var err error
+
+	if p.Type == "time.Duration" {
+		var d time.Duration
+		d, err = time.ParseDuration(tag)
+		f = float64(d)
+	} else {
+		f, err = strconv.ParseFloat(tag, 64)
+	}
+
+	if err != nil {
+		logger.Error("plugins", "Invalid limit '%s' for %s: %s", tag, p.Name, err.Error())
+		return nil
+	}
+
+	return &f
+}
+
+// parseDefault parses a default tag. Composite types take JSON.
+func parseDefault(p Parameter, tag string) interface{} {
+	value, err := parseValue(p, tag)
+	if err == nil {
+		return value
+	}
+
+	err = json.Unmarshal([]byte(tag), &value)
+	if err != nil {
+		logger.Error("plugins", "Invalid default '%s' for %s: %s", tag, p.Name, err.Error())
+		return nil
+	}
+
+	return value
+}
+
+// getParam describes a field of type t. Constraints are read from the
+// tags "default", "required", "min", "max", "pattern" and "secret".
+func getParam(name string, t reflect.Type, tag reflect.StructTag) Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	p := Parameter{
+		Name:        name,
+		Type:        t.String(),
+		Description: tag.Get("description"),
+		EnumValues:  []string{},
+		Required:    tag.Get("required") == "true",
+		Pattern:     tag.Get("pattern"),
+		Secret:      tag.Get("secret") == "true",
+		kind:        t.Kind(),
+	}
+
+	enum := tag.Get("enum")
+
+	switch {
+	case enum != "":
+		p.EnumValues = strings.Split(enum, ",")
+		p.Type = "enum"
+	case t == timeType || t == durationType:
+	case t.Kind() == reflect.Struct:
+		p.Type = "object"
+		p.Properties = getParams(t)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		p.Type = "array"
+		items := getParam("", t.Elem(), "")
+		p.Items = &items
+	case t.Kind() == reflect.Map:
+		p.Type = "map"
+		items := getParam("", t.Elem(), "")
+		p.Items = &items
+	}
+
+	p.Min = parseLimit(p, tag.Get("min"))
+	p.Max = parseLimit(p, tag.Get("max"))
+
+	def := tag.Get("default")
+	if def != "" {
+		p.Default = parseDefault(p, def)
+	}
+
+	return p
+}
+
+// eachField calls fn for all parameters of the struct v, including those
+// of embedded structs.
+func eachField(v reflect.Value, fn func(p Parameter, f reflect.Value) error) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+
+		if sf.Anonymous {
+			f = reflect.Indirect(f)
+			if f.Kind() == reflect.Struct {
+				err := eachField(f, fn)
+				if err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		name := fieldName(sf)
+		if name == "" {
+			continue
+		}
+
+		err := fn(getParam(name, sf.Type, sf.Tag), f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getParams(elem reflect.Type) []Parameter {
+	parameters := []Parameter{}
+
+	eachField(reflect.New(elem).Elem(), func(p Parameter, f reflect.Value) error {
+		parameters = append(parameters, p)
+		return nil
+	})
+
+	return parameters
+}
+
+// ApplyDefaults sets parameters of plugin having the zero value to their
+// default. This means that a parameter with a default cannot be set to
+// its zero value.
+func ApplyDefaults(plugin Plugin) {
+	if plugin == nil {
+		return
+	}
+
+	if d, ok := plugin.(Defaulter); ok {
+		d.ApplyDefaults()
+		return
+	}
+
+	applyDefaults(reflect.ValueOf(plugin))
+}
+
+func applyDefaults(v reflect.Value) {
+	v = reflect.Indirect(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+
+		eachField(v, func(p Parameter, f reflect.Value) error {
+			if p.Default != nil && f.IsZero() && f.CanSet() {
+				data, _ := json.Marshal(p.Default)
+
+				err := json.Unmarshal(data, f.Addr().Interface())
+				if err != nil {
+					logger.Error("plugins", "Cannot apply default to %s: %s", p.Name, err.Error())
+				}
+			}
+
+			applyDefaults(f)
+
+			return nil
+		})
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			applyDefaults(v.Index(i))
+		}
+	}
+}
+
+// Validate checks the parameters of plugin against its description. The
+// error is a *ValidationError. Constraints other than Required are not
+// checked for parameters having the zero value.
+func Validate(plugin Plugin) error {
+	if plugin == nil {
+		return invalid("plugin", "missing")
+	}
+
+	if v, ok := plugin.(Validator); ok {
+		return v.Validate()
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(plugin))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return validateStruct("", v)
+}
+
+func validateStruct(prefix string, v reflect.Value) error {
+	return eachField(v, func(p Parameter, f reflect.Value) error {
+		return validate(prefix+p.Name, p, f)
+	})
+}
+
+func validate(name string, p Parameter, v reflect.Value) error {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+
+		// Values decoded from JSON into interfaces can be of any type.
+		err := checkType(name, p, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !v.IsValid() || v.Kind() == reflect.Interface {
+		if p.Required {
+			return invalid(name, "is required")
+		}
+
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if p.Required {
+				return invalid(name, "is required")
+			}
+
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.IsZero() {
+		if p.Required {
+			return invalid(name, "is required")
+		}
+
+		return nil
+	}
+
+	var size float64
+	unit := ""
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	case reflect.String:
+		size = float64(len([]rune(v.String())))
+		unit = " characters"
+
+		if p.Secret && v.String() == RedactedSecret {
+			return invalid(name, "is redacted")
+		}
+
+		if p.Type == "enum" && !contains(p.EnumValues, v.String()) {
+			return invalid(name, "must be one of %s", strings.Join(p.EnumValues, ", "))
+		}
+
+		if p.Pattern != "" {
+			matched, err := regexp.MatchString(p.Pattern, v.String())
+			if err != nil {
+				return invalid(name, "invalid pattern: %s", err.Error())
+			}
+
+			if !matched {
+				return invalid(name, "must match %s", p.Pattern)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		size = float64(v.Len())
+		unit = " items"
+
+		if p.Items != nil {
+			for i := 0; i < v.Len(); i++ {
+				err := validate(fmt.Sprintf("%s[%d]", name, i), *p.Items, v.Index(i))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Map:
+		size = float64(v.Len())
+		unit = " items"
+
+		if p.Type == "object" && v.Type().Key().Kind() == reflect.String {
+			return validateMap(name+".", p.Properties, v)
+		}
+
+		if p.Items != nil {
+			for _, key := range v.MapKeys() {
+				err := validate(fmt.Sprintf("%s[%v]", name, key.Interface()), *p.Items, v.MapIndex(key))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return validateStruct(name+".", v)
+		}
+	}
+
+	if p.Min != nil && size < *p.Min {
+		return invalid(name, "must be at least %s%s", formatLimit(p, *p.Min), unit)
+	}
+
+	if p.Max != nil && size > *p.Max {
+		return invalid(name, "must be at most %s%s", formatLimit(p, *p.Max), unit)
+	}
+
+	return nil
+}
+
+// checkType makes sure v can be decoded into a parameter of type p.Type.
+// Numbers decoded from JSON are float64, so they are accepted for integer
+// types if they are integral and in range.
+func checkType(name string, p Parameter, v reflect.Value) error {
+	t, integer := integerTypes[p.Type]
+
+	switch {
+	case p.Type == "string", p.Type == "enum":
+		if v.Kind() != reflect.String {
+			return invalid(name, "must be a string")
+		}
+	case p.Type == "bool":
+		if v.Kind() != reflect.Bool {
+			return invalid(name, "must be true or false")
+		}
+	case p.Type == "float32", p.Type == "float64":
+		if _, ok := toFloat(v); !ok {
+			return invalid(name, "must be a number")
+		}
+	case integer:
+		f, ok := toFloat(v)
+		if !ok || f != math.Trunc(f) {
+			return invalid(name, "must be an integer")
+		}
+
+		i := reflect.New(t).Elem()
+		switch {
+		case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+			if f < 0 || f >= math.Exp2(64) || i.OverflowUint(uint64(f)) {
+				return invalid(name, "is out of range for %s", p.Type)
+			}
+		default:
+			if f < -math.Exp2(63) || f >= math.Exp2(63) || i.OverflowInt(int64(f)) {
+				return invalid(name, "is out of range for %s", p.Type)
+			}
+		}
+	case p.Type == "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return invalid(name, "must be an array")
+		}
+	case p.Type == "map", p.Type == "object":
+		if v.Kind() != reflect.Map && v.Kind() != reflect.Struct {
+			return invalid(name, "must be an object")
+		}
+	}
+
+	return nil
+}
+
+// toFloat returns the value of a number of any type.
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+func validateMap(prefix string, parameters []Parameter, v reflect.Value) error {
+	known := make(map[string]bool)
+
+	for _, p := range parameters {
+		known[p.Name] = true
+
+		err := validate(prefix+p.Name, p, v.MapIndex(reflect.ValueOf(p.Name).Convert(v.Type().Key())))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range v.MapKeys() {
+		if !known[key.String()] {
+			return invalid(prefix+key.String(), "unknown parameter")
+		}
+	}
+
+	return nil
+}
+
+// ValidateArguments checks arguments decoded from JSON against parameters.
+// It is meant for plugins implementing Describer.
+func ValidateArguments(parameters []Parameter, arguments map[string]interface{}) error {
+	return validateMap("", parameters, reflect.ValueOf(arguments))
+}
+
+// DefaultArguments adds the default of parameters missing from arguments.
+func DefaultArguments(parameters []Parameter, arguments map[string]interface{}) {
+	for _, p := range parameters {
+		_, found := arguments[p.Name]
+		if !found && p.Default != nil {
+			arguments[p.Name] = p.Default
+		}
+	}
+}
+
+func formatLimit(p Parameter, limit float64) string {
+	if p.Type == "time.Duration" {
+		return time.Duration(limit).String()
+	}
+
+	return strconv.FormatFloat(limit, 'f', -1, 64)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
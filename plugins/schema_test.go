@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateArguments(t *testing.T) {
+	var parameters []Parameter
+	err := json.Unmarshal([]byte(`[
+		{"name": "host", "type": "string", "required": true},
+		{"name": "port", "type": "int", "max": 65535},
+		{"name": "small", "type": "uint8"},
+		{"name": "ratio", "type": "float64"},
+		{"name": "tls", "type": "bool"},
+		{"name": "method", "type": "enum", "enumValues": ["GET", "HEAD"]},
+		{"name": "timeout", "type": "time.Duration"},
+		{"name": "codes", "type": "array", "items": {"type": "int"}},
+		{"name": "headers", "type": "map", "items": {"type": "string"}},
+		{"name": "auth", "type": "object", "properties": [{"name": "user", "type": "string"}]}
+	]`), &parameters)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	cases := []struct {
+		arguments string
+		valid     bool
+	}{
+		{`{"host": "example.com"}`, true},
+		{`{"host": "example.com", "port": 443, "small": 255, "ratio": 1.5, "tls": true, "method": "GET", "timeout": 1000000000}`, true},
+		{`{"host": "example.com", "codes": [200, 301], "headers": {"Accept": "*/*"}, "auth": {"user": "alerto"}}`, true},
+		{`{}`, false},
+		{`{"host": 42}`, false},
+		{`{"host": "example.com", "port": "not a number"}`, false},
+		{`{"host": "example.com", "port": 1.5}`, false},
+		{`{"host": "example.com", "port": 1e30}`, false},
+		{`{"host": "example.com", "port": 70000}`, false},
+		{`{"host": "example.com", "small": 256}`, false},
+		{`{"host": "example.com", "small": -1}`, false},
+		{`{"host": "example.com", "ratio": "1.5"}`, false},
+		{`{"host": "example.com", "tls": "yes"}`, false},
+		{`{"host": "example.com", "method": 1}`, false},
+		{`{"host": "example.com", "timeout": "10s"}`, false},
+		{`{"host": "example.com", "codes": 200}`, false},
+		{`{"host": "example.com", "codes": [200, "301"]}`, false},
+		{`{"host": "example.com", "headers": ["Accept"]}`, false},
+		{`{"host": "example.com", "headers": {"Accept": 1}}`, false},
+		{`{"host": "example.com", "auth": "alerto"}`, false},
+		{`{"host": "example.com", "auth": {"user": true}}`, false},
+		{`{"host": "example.com", "unknown": 1}`, false},
+	}
+
+	for _, c := range cases {
+		var arguments map[string]interface{}
+		err := json.Unmarshal([]byte(c.arguments), &arguments)
+		if err != nil {
+			t.Fatalf("Unmarshal() failed: %s", err.Error())
+		}
+
+		err = ValidateArguments(parameters, arguments)
+		if c.valid && err != nil {
+			t.Errorf("%s: Unexpected error: %s", c.arguments, err.Error())
+		}
+
+		if !c.valid && err == nil {
+			t.Errorf("%s: Expected error", c.arguments)
+		}
+
+		if err != nil {
+			if _, ok := err.(*ValidationError); !ok {
+				t.Errorf("%s: Expected *ValidationError, got %T", c.arguments, err)
+			}
+		}
+	}
+}
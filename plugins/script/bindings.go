@@ -0,0 +1,543 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// environment is the outside world as seen by a script.
+	environment interface {
+		exec(cmd plugins.Command) (plugins.ExecResult, error)
+		readFile(path string) (io.Reader, error)
+		dial(network string, address string) (net.Conn, error)
+		roundTrip(request *http.Request) (*http.Response, error)
+	}
+
+	// transportEnvironment reaches the world through the transport of the
+	// host the monitor runs on.
+	transportEnvironment struct {
+		transport plugins.Transport
+		client    *http.Client
+	}
+
+	bindings struct {
+		env      environment
+		deadline time.Time
+		conns    []net.Conn
+	}
+)
+
+var (
+	ErrorDeadline error = errors.New("Script deadline exceeded")
+
+	resultConstructor      = starlark.String("result")
+	measurementConstructor = starlark.String("measurement")
+)
+
+func (e *transportEnvironment) exec(cmd plugins.Command) (plugins.ExecResult, error) {
+	return plugins.Exec(e.transport, cmd)
+}
+
+func (e *transportEnvironment) readFile(path string) (io.Reader, error) {
+	return e.transport.ReadFile(path)
+}
+
+func (e *transportEnvironment) dial(network string, address string) (net.Conn, error) {
+	return e.transport.Dial(network, address)
+}
+
+func (e *transportEnvironment) roundTrip(request *http.Request) (*http.Response, error) {
+	if e.client == nil {
+		e.client = &http.Client{
+			Transport: &http.Transport{
+				Dial:                e.transport.Dial,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		}
+	}
+
+	return e.client.Do(request)
+}
+
+func newBindings(env environment, timeout time.Duration) *bindings {
+	return &bindings{
+		env:      env,
+		deadline: time.Now().Add(timeout),
+	}
+}
+
+// close closes connections left open by the script.
+func (b *bindings) close() {
+	for _, c := range b.conns {
+		c.Close()
+	}
+}
+
+// readAll reads r, failing if there is more than MaxDataSize bytes.
+func readAll(r io.Reader) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(MaxDataSize)+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) > MaxDataSize {
+		return "", fmt.Errorf("data exceeds %d bytes", MaxDataSize)
+	}
+
+	return string(data), nil
+}
+
+// checkSize fails if a value of size bytes would be returned by fn. Lists
+// count the overhead of each item.
+func checkSize(fn *starlark.Builtin, size int) error {
+	if size > MaxDataSize {
+		return fmt.Errorf("%s: result exceeds %d bytes", fn.Name(), MaxDataSize)
+	}
+
+	return nil
+}
+
+// stringList returns strs as a list, if it's within MaxDataSize.
+func stringList(fn *starlark.Builtin, strs []string) (starlark.Value, error) {
+	size := 0
+	list := make([]starlark.Value, len(strs))
+	for i, s := range strs {
+		size += stringSize + len(s)
+		list[i] = starlark.String(s)
+	}
+
+	err := checkSize(fn, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return starlark.NewList(list), nil
+}
+
+func module(name string, members starlark.StringDict) *starlarkstruct.Module {
+	return &starlarkstruct.Module{Name: name, Members: members}
+}
+
+type builtinFunc func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+
+func builtin(name string, fn builtinFunc) *starlark.Builtin {
+	return starlark.NewBuiltin(name, fn)
+}
+
+// predeclared returns the names available to scripts.
+func (b *bindings) predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"OK":          starlark.MakeInt(plugins.Ok),
+		"FAILED":      starlark.MakeInt(plugins.Failed),
+		"result":      builtin("result", b.result),
+		"measurement": builtin("measurement", measurement),
+		"transport": module("transport", starlark.StringDict{
+			"exec":      builtin("exec", b.exec),
+			"read_file": builtin("read_file", b.readFile),
+			"dial":      builtin("dial", b.dial),
+		}),
+		"http": module("http", starlark.StringDict{
+			"get":  builtin("get", b.http),
+			"post": builtin("post", b.http),
+		}),
+		"json": json.Module,
+		"re": module("re", starlark.StringDict{
+			"search":   builtin("search", reSearch),
+			"find_all": builtin("find_all", reFindAll),
+			"sub":      builtin("sub", reSub),
+			"split":    builtin("split", reSplit),
+		}),
+	}
+}
+
+// result(status, text="", measurements={}, details={})
+func (b *bindings) result(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var status int
+	var text string
+	measurements := starlark.NewDict(0)
+	details := starlark.NewDict(0)
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "status", &status, "text?", &text, "measurements?", &measurements, "details?", &details)
+	if err != nil {
+		return nil, err
+	}
+
+	return starlarkstruct.FromStringDict(resultConstructor, starlark.StringDict{
+		"status":       starlark.MakeInt(status),
+		"text":         starlark.String(text),
+		"measurements": measurements,
+		"details":      details,
+	}), nil
+}
+
+// measurement(value, unit="", kind="", min=None, max=None) describes a
+// measured value. See plugins.Measurement.
+func measurement(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value starlark.Value
+	var unit, kind string
+	var min, max starlark.Value = starlark.None, starlark.None
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "value", &value, "unit?", &unit, "kind?", &kind, "min?", &min, "max?", &max)
+	if err != nil {
+		return nil, err
+	}
+
+	return starlarkstruct.FromStringDict(measurementConstructor, starlark.StringDict{
+		"value": value,
+		"unit":  starlark.String(unit),
+		"kind":  starlark.String(kind),
+		"min":   min,
+		"max":   max,
+	}), nil
+}
+
+// exec(cmd, *args, stdin="", env={}, dir="", sudo=False) returns a struct
+// with stdout, stderr, exit_code, truncated and error, which is None unless
+// the command could not be executed.
+func (b *bindings) exec(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%s: expected a command and its arguments", fn.Name())
+	}
+
+	strs := make([]string, len(args))
+	for i, a := range args {
+		s, ok := starlark.AsString(a)
+		if !ok {
+			return nil, fmt.Errorf("%s: argument %d is not a string", fn.Name(), i+1)
+		}
+		strs[i] = s
+	}
+
+	var stdin, dir string
+	var sudo bool
+	env := starlark.NewDict(0)
+
+	err := starlark.UnpackArgs(fn.Name(), nil, kwargs, "stdin?", &stdin, "env?", &env, "dir?", &dir, "sudo?", &sudo)
+	if err != nil {
+		return nil, err
+	}
+
+	// A timeout of zero or less would mean no timeout at all.
+	timeout := time.Until(b.deadline)
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%s: %s", fn.Name(), ErrorDeadline.Error())
+	}
+
+	cmd := plugins.Command{
+		Path:        strs[0],
+		Args:        strs[1:],
+		Env:         make(map[string]string, env.Len()),
+		Dir:         dir,
+		Sudo:        sudo,
+		StdoutLimit: int64(MaxDataSize),
+		StderrLimit: int64(MaxDataSize),
+		Timeout:     timeout,
+	}
+
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	for _, item := range env.Items() {
+		k, ok1 := starlark.AsString(item[0])
+		v, ok2 := starlark.AsString(item[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s: env must map strings to strings", fn.Name())
+		}
+
+		cmd.Env[k] = v
+	}
+
+	r, execErr := b.env.exec(cmd)
+
+	var e starlark.Value = starlark.None
+	if execErr != nil {
+		e = starlark.String(execErr.Error())
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("exec"), starlark.StringDict{
+		"stdout":    starlark.String(r.Stdout),
+		"stderr":    starlark.String(r.Stderr),
+		"exit_code": starlark.MakeInt(r.ExitCode),
+		"truncated": starlark.Bool(r.Truncated),
+		"error":     e,
+	}), nil
+}
+
+// read_file(path) returns the content of a file.
+func (b *bindings) readFile(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "path", &path)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		content string
+		err     error
+	}
+
+	// Transports can't interrupt a read, so the script stops waiting at the
+	// deadline and leaves the read to finish in the background.
+	done := make(chan result, 1)
+	go func() {
+		r, err := b.env.readFile(path)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+
+		content, err := readAll(r)
+		done <- result{content, err}
+	}()
+
+	timer := time.NewTimer(time.Until(b.deadline))
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+
+		return starlark.String(res.content), nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: %s", fn.Name(), ErrorDeadline.Error())
+	}
+}
+
+// dial(network, address) returns a connection with send(data), recv(max)
+// and close().
+func (b *bindings) dial(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var network, address string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "network", &network, "address", &address)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := b.env.dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	b.conns = append(b.conns, c)
+	c.SetDeadline(b.deadline)
+
+	send := func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var data string
+
+		err := starlark.UnpackArgs(fn.Name(), args, kwargs, "data", &data)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := c.Write([]byte(data))
+
+		return starlark.MakeInt(n), err
+	}
+
+	recv := func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		max := 4096
+
+		err := starlark.UnpackArgs(fn.Name(), args, kwargs, "max?", &max)
+		if err != nil {
+			return nil, err
+		}
+
+		if max < 1 || max > MaxDataSize {
+			return nil, fmt.Errorf("%s: max must be between 1 and %d", fn.Name(), MaxDataSize)
+		}
+
+		buf := make([]byte, max)
+		n, err := c.Read(buf)
+		if err == io.EOF {
+			err = nil
+		}
+
+		return starlark.String(buf[:n]), err
+	}
+
+	close := func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return starlark.None, c.Close()
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("conn"), starlark.StringDict{
+		"send":  builtin("send", send),
+		"recv":  builtin("recv", recv),
+		"close": builtin("close", close),
+	}), nil
+}
+
+// get(url, headers={}) and post(url, body="", headers={}) return a struct
+// with status, headers and body.
+func (b *bindings) http(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url, body string
+	headers := starlark.NewDict(0)
+
+	var err error
+	method := strings.ToUpper(fn.Name())
+	if method == "POST" {
+		err = starlark.UnpackArgs(fn.Name(), args, kwargs, "url", &url, "body?", &body, "headers?", &headers)
+	} else {
+		err = starlark.UnpackArgs(fn.Name(), args, kwargs, "url", &url, "headers?", &headers)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline covers reading the body too.
+	ctx, cancel := context.WithDeadline(context.Background(), b.deadline)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range headers.Items() {
+		k, ok1 := starlark.AsString(item[0])
+		v, ok2 := starlark.AsString(item[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s: headers must map strings to strings", fn.Name())
+		}
+
+		request.Header.Set(k, v)
+	}
+
+	response, err := b.env.roundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := readAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	h := starlark.NewDict(len(response.Header))
+	for k := range response.Header {
+		h.SetKey(starlark.String(strings.ToLower(k)), starlark.String(response.Header.Get(k)))
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("response"), starlark.StringDict{
+		"status":  starlark.MakeInt(response.StatusCode),
+		"headers": h,
+		"body":    starlark.String(content),
+	}), nil
+}
+
+func compile(fn *starlark.Builtin, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fn.Name(), err.Error())
+	}
+
+	return re, nil
+}
+
+// search(pattern, s) returns the match and its groups as a list, or None.
+func reSearch(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "pattern", &pattern, "s", &s)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compile(fn, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		return starlark.None, nil
+	}
+
+	return stringList(fn, match)
+}
+
+// find_all(pattern, s) returns all matches.
+func reFindAll(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "pattern", &pattern, "s", &s)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compile(fn, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return stringList(fn, re.FindAllString(s, -1))
+}
+
+// sub(pattern, repl, s) replaces all matches. repl can refer to groups
+// like ${1}.
+func reSub(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, repl, s string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "pattern", &pattern, "repl", &repl, "s", &s)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compile(fn, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	result := re.ReplaceAllString(s, repl)
+
+	err = checkSize(fn, len(result))
+	if err != nil {
+		return nil, err
+	}
+
+	return starlark.String(result), nil
+}
+
+// split(pattern, s) splits s around matches.
+func reSplit(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "pattern", &pattern, "s", &s)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compile(fn, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return stringList(fn, re.Split(s, -1))
+}
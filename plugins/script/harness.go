@@ -0,0 +1,136 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Harness runs scripts against canned data instead of a transport,
+	// making it possible to test a script before deploying it.
+	Harness struct {
+		// Commands maps a command line, with arguments separated by
+		// single spaces, to its output.
+		Commands map[string]Command `json:"commands"`
+
+		// Files maps paths to their content.
+		Files map[string]string `json:"files"`
+
+		// HTTP maps "METHOD url" to a response.
+		HTTP map[string]Response `json:"http"`
+
+		// Dial maps "network address" to the data sent by the remote
+		// end. The remote end closes the connection after sending it.
+		Dial map[string]string `json:"dial"`
+	}
+
+	Command struct {
+		Stdout   string `json:"stdout"`
+		Stderr   string `json:"stderr"`
+		ExitCode int    `json:"exitCode"`
+
+		// Error is returned if the command can't be executed.
+		Error string `json:"error"`
+	}
+
+	Response struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+
+	harnessEnvironment struct {
+		harness *Harness
+	}
+)
+
+var (
+	ErrorNotInHarness error = errors.New("Not found in harness")
+)
+
+// Run runs source against the canned data in h.
+func (h *Harness) Run(source string, timeout time.Duration) plugins.Result {
+	return run(source, &harnessEnvironment{harness: h}, timeout)
+}
+
+func (e *harnessEnvironment) exec(cmd plugins.Command) (plugins.ExecResult, error) {
+	line := strings.Join(append([]string{cmd.Path}, cmd.Args...), " ")
+
+	c, found := e.harness.Commands[line]
+	if !found {
+		return plugins.ExecResult{}, fmt.Errorf("%s: %s", ErrorNotInHarness.Error(), line)
+	}
+
+	if c.Error != "" {
+		return plugins.ExecResult{}, errors.New(c.Error)
+	}
+
+	return plugins.ExecResult{
+		Stdout:   []byte(c.Stdout),
+		Stderr:   []byte(c.Stderr),
+		ExitCode: c.ExitCode,
+	}, nil
+}
+
+func (e *harnessEnvironment) readFile(path string) (io.Reader, error) {
+	content, found := e.harness.Files[path]
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotInHarness.Error(), path)
+	}
+
+	return strings.NewReader(content), nil
+}
+
+func (e *harnessEnvironment) dial(network string, address string) (net.Conn, error) {
+	key := network + " " + address
+
+	reply, found := e.harness.Dial[key]
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotInHarness.Error(), key)
+	}
+
+	client, server := net.Pipe()
+
+	go io.Copy(ioutil.Discard, server)
+	go func() {
+		server.Write([]byte(reply))
+		server.Close()
+	}()
+
+	return client, nil
+}
+
+func (e *harnessEnvironment) roundTrip(request *http.Request) (*http.Response, error) {
+	key := request.Method + " " + request.URL.String()
+
+	r, found := e.harness.HTTP[key]
+	if !found {
+		return nil, fmt.Errorf("%s: %s", ErrorNotInHarness.Error(), key)
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(r.Body)),
+		Request:    request,
+	}, nil
+}
@@ -0,0 +1,244 @@
+package script
+
+import (
+	"fmt"
+	"unsafe"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Memory is accounted per script. Every memoryCheckSteps Starlark steps,
+// the size of the values reachable from the globals of the script and the
+// local variables of running functions is estimated. Values returned by
+// bindings are limited to MaxDataSize, so a script can't get far beyond
+// MaxMemory between checks. Values not yet stored in a variable, like a
+// list being built by a comprehension, are not seen until they are.
+
+type (
+	// accountant limits the steps and memory used by a script.
+	accountant struct {
+		// locals is the number of local variables of each function,
+		// found by the position of its def or lambda.
+		locals       map[syntax.Position]int
+		moduleLocals int
+	}
+
+	// measurer adds up the size of values, counting shared values once.
+	measurer struct {
+		size  uint64
+		count uint64
+		seen  map[interface{}]bool
+	}
+)
+
+const (
+	// Rough sizes of the Go representation of values.
+	wordSize   = 8
+	valueSize  = 2 * wordSize
+	stringSize = 2 * wordSize
+	sliceSize  = 3 * wordSize
+	entrySize  = 2*valueSize + 2*wordSize
+	dictSize   = 8 * wordSize
+
+	// Strings shorter than this are not worth deduplicating.
+	sharedStringSize = 64
+)
+
+var (
+	memoryCheckSteps uint64 = 10000
+)
+
+// newAccountant returns an accountant for the resolved file f.
+func newAccountant(f *syntax.File) *accountant {
+	a := &accountant{
+		locals: make(map[syntax.Position]int),
+	}
+
+	if module, ok := f.Module.(*resolve.Module); ok {
+		a.moduleLocals = len(module.Locals)
+	}
+
+	var visit func(n syntax.Node) bool
+	visit = func(n syntax.Node) bool {
+		var fn interface{}
+
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			fn = n.Function
+		case *syntax.LambdaExpr:
+			fn = n.Function
+		case *syntax.WhileStmt:
+			// syntax.Walk doesn't know while loops.
+			syntax.Walk(n.Cond, visit)
+			for _, stmt := range n.Body {
+				syntax.Walk(stmt, visit)
+			}
+			return false
+		}
+
+		if fn, ok := fn.(*resolve.Function); ok {
+			a.locals[fn.Pos] = len(fn.Locals)
+		}
+
+		return true
+	}
+
+	syntax.Walk(f, visit)
+
+	return a
+}
+
+// install makes thread call check every memoryCheckSteps steps.
+func (a *accountant) install(thread *starlark.Thread) {
+	thread.OnMaxSteps = a.check
+	thread.SetMaxExecutionSteps(min(memoryCheckSteps, MaxSteps))
+}
+
+// check is called by the interpreter of thread. It cancels thread if the
+// script used too many steps or too much memory.
+func (a *accountant) check(thread *starlark.Thread) {
+	steps := thread.ExecutionSteps()
+	if steps >= MaxSteps {
+		thread.Cancel("too many steps")
+		return
+	}
+
+	m := a.measure(thread)
+	if m.size > MaxMemory {
+		thread.Cancel(fmt.Sprintf("memory limit of %d bytes exceeded", MaxMemory))
+		return
+	}
+
+	// Measuring takes time proportional to the number of values, so
+	// scripts with many values are checked less often.
+	thread.SetMaxExecutionSteps(min(steps+max(memoryCheckSteps, m.count), MaxSteps))
+}
+
+// measure estimates the memory used by the values of the script running
+// in thread. It stops early if the size exceeds MaxMemory.
+func (a *accountant) measure(thread *starlark.Thread) *measurer {
+	m := &measurer{seen: make(map[interface{}]bool)}
+	globals := false
+
+	for depth := 0; depth < thread.CallStackDepth(); depth++ {
+		frame := thread.DebugFrame(depth)
+
+		fn, ok := frame.Callable().(*starlark.Function)
+		if !ok {
+			continue
+		}
+
+		// All functions of a script share the same globals.
+		if !globals {
+			for _, value := range fn.Globals() {
+				m.add(value)
+			}
+			globals = true
+		}
+
+		n := a.locals[fn.Position()]
+		if fn.Name() == "<toplevel>" {
+			n = a.moduleLocals
+		}
+
+		for i := 0; i < n; i++ {
+			m.add(frame.Local(i))
+		}
+	}
+
+	return m
+}
+
+// visit returns true the first time it's called for key.
+func (m *measurer) visit(key interface{}) bool {
+	if m.seen[key] {
+		return false
+	}
+
+	m.seen[key] = true
+
+	return true
+}
+
+func (m *measurer) add(value starlark.Value) {
+	if value == nil || m.size > MaxMemory {
+		return
+	}
+
+	m.count++
+
+	switch v := value.(type) {
+	case starlark.String:
+		if len(v) < sharedStringSize || m.visit(unsafe.StringData(string(v))) {
+			m.size += stringSize + uint64(len(v))
+		}
+	case starlark.Bytes:
+		if len(v) < sharedStringSize || m.visit(unsafe.StringData(string(v))) {
+			m.size += stringSize + uint64(len(v))
+		}
+	case starlark.Int:
+		m.size += valueSize
+		if _, ok := v.Int64(); !ok {
+			m.size += uint64(v.BigInt().BitLen() / 8)
+		}
+	case starlark.Tuple:
+		m.size += sliceSize + uint64(len(v))*valueSize
+		for _, item := range v {
+			m.add(item)
+		}
+	case *starlark.List:
+		if !m.visit(v) {
+			return
+		}
+
+		m.size += sliceSize + uint64(v.Len())*valueSize
+		for i := 0; i < v.Len(); i++ {
+			m.add(v.Index(i))
+		}
+	case *starlark.Dict:
+		if !m.visit(v) {
+			return
+		}
+
+		m.size += dictSize + uint64(v.Len())*entrySize
+		m.addIterable(v, func(key starlark.Value) {
+			m.add(key)
+
+			item, _, _ := v.Get(key)
+			m.add(item)
+		})
+	case *starlark.Set:
+		if !m.visit(v) {
+			return
+		}
+
+		m.size += dictSize + uint64(v.Len())*entrySize
+		m.addIterable(v, m.add)
+	case *starlarkstruct.Struct:
+		if !m.visit(v) {
+			return
+		}
+
+		names := v.AttrNames()
+		m.size += sliceSize + uint64(len(names))*entrySize
+		for _, name := range names {
+			item, _ := v.Attr(name)
+			m.add(item)
+		}
+	default:
+		m.size += valueSize
+	}
+}
+
+func (m *measurer) addIterable(iterable starlark.Iterable, fn func(starlark.Value)) {
+	iter := iterable.Iterate()
+	defer iter.Done()
+
+	var item starlark.Value
+	for iter.Next(&item) && m.size <= MaxMemory {
+		fn(item)
+	}
+}
@@ -0,0 +1,277 @@
+// Package script implements an agent running checks written in Starlark,
+// a Python dialect designed for embedding. Scripts must define check(),
+// returning the value of result(status, text, measurements, details). See
+// predeclared for the bindings available.
+package script
+
+import (
+	"fmt"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+func init() {
+	plugins.Register("script", NewScript)
+}
+
+func NewScript() plugins.Plugin {
+	return new(Script)
+}
+
+type (
+	Script struct {
+		Source string `json:"source" description:"Starlark source defining check()" required:"true"`
+	}
+)
+
+var (
+	// MaxSteps limits the CPU time used by a script by limiting the
+	// number of Starlark instructions executed.
+	MaxSteps uint64 = 10000000
+
+	// MaxMemory limits the estimated size of the values kept by a
+	// script.
+	MaxMemory uint64 = 64 << 20
+
+	// MaxDataSize limits the size of data passed to and returned by
+	// bindings.
+	MaxDataSize = 1 << 20
+
+	// Steps and memory are limited, so loops and recursion are safe to
+	// allow.
+	fileOptions = &syntax.FileOptions{
+		Set:       true,
+		While:     true,
+		Recursion: true,
+	}
+)
+
+func (s Script) GetInfo() plugins.HumanInfo {
+	return plugins.HumanInfo{
+		Name:        "Script",
+		Description: "Run a check written in Starlark",
+	}
+}
+
+// Privileged is true, as scripts can run commands through the transport.
+func (s *Script) Privileged() bool {
+	return true
+}
+
+func (s *Script) Run(transport plugins.Transport, request plugins.Request) plugins.Result {
+	return run(s.Source, &transportEnvironment{transport: transport}, request.Timeout)
+}
+
+// watch cancels thread if it runs for longer than timeout. It returns a
+// function stopping the watch.
+func watch(thread *starlark.Thread, timeout time.Duration) func() {
+	timer := time.AfterFunc(timeout, func() {
+		thread.Cancel(fmt.Sprintf("timeout after %s", timeout))
+	})
+
+	return func() {
+		timer.Stop()
+	}
+}
+
+// run executes source in env and calls its check function.
+func run(source string, env environment, timeout time.Duration) plugins.Result {
+	thread := &starlark.Thread{
+		Name: "check",
+		Print: func(thread *starlark.Thread, msg string) {
+			// Scripts have nowhere to print.
+		},
+	}
+
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	b := newBindings(env, timeout)
+	defer b.close()
+
+	predeclared := b.predeclared()
+
+	f, program, err := starlark.SourceProgramOptions(fileOptions, "check.star", source, predeclared.Has)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", errorText(err))
+	}
+
+	newAccountant(f).install(thread)
+
+	stop := watch(thread, timeout)
+	defer stop()
+
+	globals, err := program.Init(thread, predeclared)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", errorText(err))
+	}
+	globals.Freeze()
+
+	check, found := globals["check"]
+	if !found {
+		return plugins.NewResult(plugins.Failed, nil, "script does not define check()")
+	}
+
+	value, err := starlark.Call(thread, check, nil, nil)
+	if err != nil {
+		return plugins.NewResult(plugins.Failed, nil, "%s", errorText(err))
+	}
+
+	return toResult(value)
+}
+
+func errorText(err error) string {
+	if e, ok := err.(*starlark.EvalError); ok {
+		return e.Backtrace()
+	}
+
+	return err.Error()
+}
+
+// toMeasurement converts a number or the value of measurement().
+func toMeasurement(value starlark.Value) (plugins.Measurement, bool) {
+	f, ok := starlark.AsFloat(value)
+	if ok {
+		return plugins.Measurement{Value: f}, true
+	}
+
+	s, ok := value.(*starlarkstruct.Struct)
+	if !ok || s.Constructor() != measurementConstructor {
+		return plugins.Measurement{}, false
+	}
+
+	v, _ := s.Attr("value")
+	unit, _ := s.Attr("unit")
+	kind, _ := s.Attr("kind")
+	min, _ := s.Attr("min")
+	max, _ := s.Attr("max")
+
+	var m plugins.Measurement
+	m.Value, ok = starlark.AsFloat(v)
+	if !ok {
+		return m, false
+	}
+
+	u, _ := starlark.AsString(unit)
+	k, _ := starlark.AsString(kind)
+	m.Unit = plugins.Unit(u)
+	m.Kind = plugins.Kind(k)
+
+	if f, ok := starlark.AsFloat(min); ok {
+		m.Min = &f
+	}
+
+	if f, ok := starlark.AsFloat(max); ok {
+		m.Max = &f
+	}
+
+	return m, true
+}
+
+// toResult converts the return value of check() to a result.
+func toResult(value starlark.Value) plugins.Result {
+	s, ok := value.(*starlarkstruct.Struct)
+	if !ok || s.Constructor() != resultConstructor {
+		return plugins.NewResult(plugins.Failed, nil, "check() must return result(), not %s", value.Type())
+	}
+
+	status, _ := s.Attr("status")
+	text, _ := s.Attr("text")
+	measurements, _ := s.Attr("measurements")
+
+	code, err := starlark.AsInt32(status)
+	if err != nil || (code != plugins.Ok && code != plugins.Failed) {
+		return plugins.NewResult(plugins.Failed, nil, "invalid status %s", status)
+	}
+
+	c := plugins.NewMeasurementCollection()
+	if d, ok := measurements.(*starlark.Dict); ok {
+		for _, item := range d.Items() {
+			key, ok := starlark.AsString(item[0])
+			m, isMeasurement := toMeasurement(item[1])
+			if !ok || !isMeasurement {
+				return plugins.NewResult(plugins.Failed, nil, "measurements must map strings to numbers or measurement()")
+			}
+
+			c.Add(key, m)
+		}
+	}
+
+	t, _ := starlark.AsString(text)
+
+	r := plugins.NewResult(plugins.Status(code), c, "%s", t)
+
+	details, _ := s.Attr("details")
+	if d, ok := details.(*starlark.Dict); ok && d.Len() > 0 {
+		v, err := toGo(d)
+		if err != nil {
+			return plugins.NewResult(plugins.Failed, nil, "details: %s", err.Error())
+		}
+
+		r.Details = plugins.Details(v.(map[string]interface{}))
+	}
+
+	return r
+}
+
+// toGo converts Starlark values to values encodable as JSON.
+func toGo(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s out of range", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.List, starlark.Tuple:
+		list := []interface{}{}
+		iter := starlark.Iterate(v)
+		defer iter.Done()
+
+		var item starlark.Value
+		for iter.Next(&item) {
+			g, err := toGo(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, g)
+		}
+
+		return list, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("keys must be strings, not %s", item[0].Type())
+			}
+
+			g, err := toGo(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = g
+		}
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("%s can not be converted", value.Type())
+}
+
+// Ensure compliance
+var _ plugins.Agent = (*Script)(nil)
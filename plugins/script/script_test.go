@@ -0,0 +1,308 @@
+package script
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+
+	"github.com/abrander/alerto/plugins"
+)
+
+var (
+	harness = &Harness{
+		Commands: map[string]Command{
+			"df -P /":                   {Stdout: "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 100 91 9 91% /\n"},
+			"systemctl is-active nginx": {Stdout: "inactive\n", ExitCode: 3},
+		},
+		Files: map[string]string{
+			"/proc/loadavg": "0.50 0.40 0.30 1/100 1234\n",
+		},
+		HTTP: map[string]Response{
+			"GET http://example.com/health": {Body: `{"status": "ok", "queue": 12}`, Headers: map[string]string{"Content-Type": "application/json"}},
+		},
+		Dial: map[string]string{
+			"tcp example.com:25": "220 example.com ESMTP\r\n",
+		},
+	}
+)
+
+func TestHarnessRun(t *testing.T) {
+	cases := []struct {
+		name         string
+		source       string
+		status       plugins.Status
+		text         string
+		measurements map[string]float64
+	}{
+		{
+			name: "read_file",
+			source: `
+def check():
+    avg = float(transport.read_file("/proc/loadavg").split(" ")[0])
+    return result(OK if avg < 1 else FAILED, "load %s" % avg, {"load": avg})
+`,
+			status:       plugins.Ok,
+			text:         "load 0.5",
+			measurements: map[string]float64{"load": 0.5},
+		},
+		{
+			name: "exec",
+			source: `
+def check():
+    r = transport.exec("df", "-P", "/")
+    used = int(re.search(r"(\d+)%", r.stdout)[1])
+    return result(FAILED if used > 90 else OK, measurements={"used": measurement(used, unit="%")})
+`,
+			status:       plugins.Failed,
+			measurements: map[string]float64{"used": 91},
+		},
+		{
+			name: "exit code",
+			source: `
+def check():
+    r = transport.exec("systemctl", "is-active", "nginx")
+    return result(OK if r.exit_code == 0 else FAILED, r.stdout.strip())
+`,
+			status: plugins.Failed,
+			text:   "inactive",
+		},
+		{
+			name: "http",
+			source: `
+def check():
+    r = http.get("http://example.com/health")
+    body = json.decode(r.body)
+    return result(OK if body["status"] == "ok" else FAILED, r.headers["content-type"], {"queue": body["queue"]})
+`,
+			status:       plugins.Ok,
+			text:         "application/json",
+			measurements: map[string]float64{"queue": 12},
+		},
+		{
+			name: "dial",
+			source: `
+def check():
+    c = transport.dial("tcp", "example.com:25")
+    c.send("QUIT\r\n")
+    banner = c.recv()
+    c.close()
+    return result(OK if banner.startswith("220") else FAILED, banner.strip())
+`,
+			status: plugins.Ok,
+			text:   "220 example.com ESMTP",
+		},
+		{
+			name:   "not in harness",
+			source: `def check(): return result(OK, transport.read_file("/etc/shadow"))`,
+			status: plugins.Failed,
+			text:   ErrorNotInHarness.Error(),
+		},
+		{
+			name:   "no check",
+			source: `x = 1`,
+			status: plugins.Failed,
+			text:   "does not define check()",
+		},
+		{
+			name:   "wrong return",
+			source: `def check(): return 1`,
+			status: plugins.Failed,
+			text:   "must return result()",
+		},
+		{
+			name:   "syntax error",
+			source: `def check(:`,
+			status: plugins.Failed,
+			text:   "check.star:1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := harness.Run(c.source, time.Second)
+
+			if r.Status != c.status {
+				t.Errorf("Expected status %d, got %d: %s", c.status, r.Status, r.Text)
+			}
+
+			if !strings.Contains(r.Text, c.text) {
+				t.Errorf("Expected text containing %q, got %q", c.text, r.Text)
+			}
+
+			if len(c.measurements) > 0 && r.Measurements == nil {
+				t.Fatalf("No measurements")
+			}
+
+			for key, value := range c.measurements {
+				m, found := (*r.Measurements)[key]
+				if !found || m.Value != value {
+					t.Errorf("Expected %s=%v, got %v", key, value, r.Measurements)
+				}
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		text   string
+	}{
+		{
+			name: "steps",
+			source: `
+def check():
+    for i in range(100000000):
+        pass
+`,
+			text: "too many steps",
+		},
+		{
+			name: "memory",
+			source: `
+def check():
+    data = []
+    for i in range(1000000):
+        data.append(str(i) * 100)
+`,
+			text: "memory limit",
+		},
+		{
+			name: "global memory",
+			source: `
+data = {}
+
+def fill():
+    for i in range(1000000):
+        data[i] = str(i) * 100
+
+fill()
+`,
+			text: "memory limit",
+		},
+		{
+			name: "binding result",
+			source: `
+def check():
+    return result(OK, re.sub("", "xxxxxxxxxx", "x" * 200000))
+`,
+			text: "result exceeds",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := harness.Run(c.source, 10*time.Second)
+
+			if r.Status != plugins.Failed || !strings.Contains(r.Text, c.text) {
+				t.Errorf("Expected failure with %q, got %d: %s", c.text, r.Status, r.Text)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	start := time.Now()
+	r := harness.Run("def check():\n    while True:\n        pass\n", 100*time.Millisecond)
+
+	if r.Status != plugins.Failed || !strings.Contains(r.Text, "timeout") {
+		t.Errorf("Expected timeout, got %d: %s", r.Status, r.Text)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Script ran for %s", time.Since(start))
+	}
+}
+
+// stuckEnvironment never answers reads and requests.
+type stuckEnvironment struct {
+	harnessEnvironment
+}
+
+func (e *stuckEnvironment) readFile(path string) (io.Reader, error) {
+	select {}
+}
+
+func (e *stuckEnvironment) roundTrip(request *http.Request) (*http.Response, error) {
+	<-request.Context().Done()
+
+	return nil, request.Context().Err()
+}
+
+func TestDeadline(t *testing.T) {
+	env := &stuckEnvironment{harnessEnvironment{harness: harness}}
+
+	cases := map[string]string{
+		"read_file": "def check():\n    transport.read_file('/proc/loadavg')\n",
+		"http":      "def check():\n    http.get('http://example.com/health')\n",
+	}
+
+	for name, source := range cases {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			r := run(source, env, 100*time.Millisecond)
+
+			if r.Status != plugins.Failed || !strings.Contains(r.Text, "deadline exceeded") {
+				t.Errorf("Expected deadline, got %d: %s", r.Status, r.Text)
+			}
+
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("Script ran for %s", time.Since(start))
+			}
+		})
+	}
+
+	// A negative timeout means none at all to exec.
+	b := newBindings(env, -time.Second)
+	_, err := starlark.Call(&starlark.Thread{}, builtin("exec", b.exec), starlark.Tuple{starlark.String("df -P /")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("exec after the deadline returned %v", err)
+	}
+}
+
+// TestMemoryPerScript checks that a script using a lot of memory doesn't
+// count against others running at the same time.
+func TestMemoryPerScript(t *testing.T) {
+	small := `
+def check():
+    data = []
+    for i in range(200000):
+        data.append(i)
+    return result(OK, "done")
+`
+	big := `
+def check():
+    data = []
+    for i in range(1000000):
+        data.append(str(i) * 100)
+`
+
+	var wg sync.WaitGroup
+	results := make([]plugins.Result, 8)
+
+	for i := range results {
+		source := small
+		if i%2 == 0 {
+			source = big
+		}
+
+		wg.Add(1)
+		go func(i int, source string) {
+			defer wg.Done()
+			results[i] = harness.Run(source, 10*time.Second)
+		}(i, source)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		if i%2 == 1 && r.Status != plugins.Ok {
+			t.Errorf("Small script failed: %s", r.Text)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package plugins
+
+import (
+	"reflect"
+)
+
+const (
+	// RedactedSecret replaces the value of secret parameters in
+	// everything shown to users.
+	RedactedSecret = "********"
+)
+
+// Redact returns a copy of plugin with secret parameters replaced by
+// RedactedSecret. plugin itself is returned if it has nothing to redact.
+func Redact(plugin Plugin) Plugin {
+	v := reflect.ValueOf(plugin)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return plugin
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+
+	if !redact(c.Elem()) {
+		return plugin
+	}
+
+	return c.Interface().(Plugin)
+}
+
+// redact replaces non-empty secret strings in the struct v. It returns
+// true if anything was replaced.
+func redact(v reflect.Value) bool {
+	redacted := false
+
+	eachField(v, func(p Parameter, f reflect.Value) error {
+		switch {
+		case p.Secret && f.Kind() == reflect.String && f.String() != "":
+			f.SetString(RedactedSecret)
+			redacted = true
+		case f.Kind() == reflect.Struct && f.Type() != timeType:
+			redacted = redact(f) || redacted
+		}
+
+		return nil
+	})
+
+	return redacted
+}
+
+// RestoreSecrets sets secret parameters of plugin that are RedactedSecret
+// to their value in previous. This allows clients to send back what they
+// were given. Both must be of the same type.
+func RestoreSecrets(plugin Plugin, previous Plugin) {
+	v := reflect.ValueOf(plugin)
+	p := reflect.ValueOf(previous)
+	if v.Kind() != reflect.Ptr || p.Kind() != reflect.Ptr || v.Type() != p.Type() || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	restore(v.Elem(), p.Elem())
+}
+
+func restore(v reflect.Value, previous reflect.Value) {
+	i := 0
+	var fields []reflect.Value
+	eachField(previous, func(p Parameter, f reflect.Value) error {
+		fields = append(fields, f)
+		return nil
+	})
+
+	eachField(v, func(p Parameter, f reflect.Value) error {
+		prev := fields[i]
+		i++
+
+		switch {
+		case p.Secret && f.Kind() == reflect.String && f.String() == RedactedSecret:
+			f.SetString(prev.String())
+		case f.Kind() == reflect.Struct && f.Type() != timeType:
+			restore(f, prev)
+		}
+
+		return nil
+	})
+}
+
+// Redacted returns a copy of job with the secret arguments redacted.
+func (job Job) Redacted() Job {
+	if job.Agent != nil {
+		job.Agent, _ = Redact(job.Agent).(Agent)
+	}
+
+	return job
+}
+
+// RestoreSecrets restores redacted secret arguments from previous, if it
+// uses the same agent.
+func (job *Job) RestoreSecrets(previous Job) {
+	if job.AgentId == previous.AgentId && job.Agent != nil && previous.Agent != nil {
+		RestoreSecrets(job.Agent, previous.Agent)
+	}
+}
@@ -0,0 +1,94 @@
+package plugins
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type (
+	secretAgent struct {
+		User     string      `json:"user"`
+		Password string      `json:"password" secret:"true"`
+		Proxy    secretProxy `json:"proxy"`
+	}
+
+	secretProxy struct {
+		Address string `json:"address"`
+		Token   string `json:"token" secret:"true"`
+	}
+)
+
+func (a *secretAgent) GetInfo() HumanInfo {
+	return HumanInfo{Name: "secret"}
+}
+
+func (a *secretAgent) Run(Transport, Request) Result {
+	return NewResult(Ok, nil, "ok")
+}
+
+func init() {
+	Register("secret-test", func() Plugin { return &secretAgent{} })
+}
+
+func TestRedact(t *testing.T) {
+	a := &secretAgent{User: "alerto", Password: "hunter2", Proxy: secretProxy{Address: "proxy:3128", Token: "abc"}}
+
+	r := Redact(a).(*secretAgent)
+	if r.Password != RedactedSecret || r.Proxy.Token != RedactedSecret || r.User != "alerto" || r.Proxy.Address != "proxy:3128" {
+		t.Errorf("Wrong redaction %+v", r)
+	}
+
+	if a.Password != "hunter2" || a.Proxy.Token != "abc" {
+		t.Errorf("Redact() changed the original %+v", a)
+	}
+
+	empty := &secretAgent{User: "alerto"}
+	if Redact(empty) != Plugin(empty) {
+		t.Errorf("Redact() copied a plugin without secrets")
+	}
+
+	RestoreSecrets(r, a)
+	if r.Password != "hunter2" || r.Proxy.Token != "abc" {
+		t.Errorf("Secrets not restored %+v", r)
+	}
+
+	changed := &secretAgent{Password: "new", Proxy: secretProxy{Token: RedactedSecret}}
+	RestoreSecrets(changed, a)
+	if changed.Password != "new" || changed.Proxy.Token != "abc" {
+		t.Errorf("Wrong restore %+v", changed)
+	}
+}
+
+func TestJobSecrets(t *testing.T) {
+	var job Job
+	err := json.Unmarshal([]byte(`{"agentId": "secret-test", "arguments": {"user": "alerto", "password": "hunter2"}}`), &job)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err.Error())
+	}
+
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), RedactedSecret) {
+		t.Errorf("Secret not redacted in %s", data)
+	}
+
+	// The client sends back what it got.
+	var update Job
+	err = json.Unmarshal(data, &update)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	if update.Validate() == nil {
+		t.Errorf("Redacted secret accepted")
+	}
+
+	update.RestoreSecrets(job)
+	if update.Agent.(*secretAgent).Password != "hunter2" || update.Validate() != nil {
+		t.Errorf("Secret not restored %+v", update.Agent)
+	}
+}
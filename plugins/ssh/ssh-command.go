@@ -2,8 +2,12 @@ package ssh
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 
 	"github.com/abrander/alerto/logger"
 	"github.com/abrander/alerto/plugins"
@@ -30,34 +34,75 @@ func (d SshCommand) GetInfo() plugins.HumanInfo {
 	}
 }
 
-func (s *SshCommand) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
-	for _, arg := range arguments {
-		cmd += " " + arg
+// Exec executes cmd through the login shell of the user, which must be a
+// POSIX shell.
+func (s *SshCommand) Exec(cmd plugins.Command) (*plugins.Process, error) {
+	line, err := cmd.ShellCommand()
+	if err != nil {
+		return nil, err
 	}
 
-	logger.Yellow("ssh", "Executing command '%s' on %s:%d as %s", cmd, s.Ssh.Host, s.Ssh.Port, s.Username)
+	logger.Yellow("ssh", "Executing command '%s' on %s:%d as %s", line, s.Ssh.Host, s.Ssh.Port, s.Username)
 	conn, err := pool.Get(s.Ssh)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	defer pool.Done(s.Ssh)
 
 	session, err := conn.NewSession()
 	if err != nil {
-		return nil, nil, err
+		pool.Done(s.Ssh)
+		return nil, err
 	}
-	defer session.Close()
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	session.Stderr = &stderrBuf
+	p := plugins.NewProcess(cmd)
+	session.Stdin = cmd.Stdin
+	session.Stdout = p.Stdout
+	session.Stderr = p.Stderr
 
-	err = session.Run(cmd)
+	err = session.Start(line)
 	if err != nil {
-		return &stdoutBuf, &stderrBuf, err
+		session.Close()
+		pool.Done(s.Ssh)
+		return nil, err
+	}
+
+	var timer *time.Timer
+	killed := make(chan struct{})
+	if cmd.Timeout > 0 {
+		timer = time.AfterFunc(cmd.Timeout, func() {
+			close(killed)
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		})
 	}
 
-	return &stdoutBuf, &stderrBuf, nil
+	go func() {
+		defer pool.Done(s.Ssh)
+		defer session.Close()
+
+		err := session.Wait()
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-killed:
+			p.Exit(-1, fmt.Errorf("killed after %s", cmd.Timeout))
+			return
+		default:
+		}
+
+		switch e := err.(type) {
+		case nil:
+			p.Exit(0, nil)
+		case *ssh.ExitError:
+			p.Exit(e.ExitStatus(), nil)
+		default:
+			p.Exit(-1, err)
+		}
+	}()
+
+	return p, nil
 }
 
 func (s *SshCommand) Dial(network string, address string) (net.Conn, error) {
@@ -73,9 +118,20 @@ func (s *SshCommand) Dial(network string, address string) (net.Conn, error) {
 }
 
 func (s *SshCommand) ReadFile(path string) (io.Reader, error) {
-	r, _, err := s.Exec("/bin/cat", path)
+	r, err := plugins.Exec(s, plugins.Command{Path: "/bin/cat", Args: []string{path}})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ExitCode != 0 {
+		return nil, fmt.Errorf("%s: %s", path, bytes.TrimSpace(r.Stderr))
+	}
+
+	if r.Truncated {
+		return nil, fmt.Errorf("%s: larger than %d bytes", path, plugins.DefaultOutputLimit)
+	}
 
-	return r, err
+	return bytes.NewReader(r.Stdout), nil
 }
 
 // Ensure compliance
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/abrander/alerto/plugins/plugintest"
+)
+
+// TestSshCommand runs the transport conformance suite against the host
+// in ALERTO_TEST_SSH_HOST, given as host or host:port. The public key in
+// ConfigDir must be authorized for ALERTO_TEST_SSH_USER, or the current
+// user, on that host.
+func TestSshCommand(t *testing.T) {
+	address := os.Getenv("ALERTO_TEST_SSH_HOST")
+	if address == "" {
+		t.Skip("ALERTO_TEST_SSH_HOST not set")
+	}
+
+	s := new(SshCommand)
+	s.Host = address
+	s.Port = 22
+	s.Username = os.Getenv("ALERTO_TEST_SSH_USER")
+	if s.Username == "" {
+		s.Username = os.Getenv("USER")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			t.Fatalf("Invalid port in ALERTO_TEST_SSH_HOST: %s", err.Error())
+		}
+
+		s.Host = host
+		s.Port = uint16(p)
+	}
+
+	plugintest.TestTransport(t, s)
+}
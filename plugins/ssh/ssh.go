@@ -18,9 +18,9 @@ import (
 
 type (
 	Ssh struct {
-		Host     string `json:"host" description:"Hostname or IP adress to connect to"`
-		Port     uint16 `json:"port" description:"TCP port to connect to" default:"22"`
-		Username string `json:"username" description:"Username"`
+		Host     string `json:"host" description:"Hostname or IP adress to connect to" required:"true"`
+		Port     uint16 `json:"port" description:"TCP port to connect to" default:"22" min:"1"`
+		Username string `json:"username" description:"Username" required:"true"`
 	}
 )
 
@@ -104,11 +104,6 @@ func init() {
 }
 
 func (s *Ssh) Connect() (*ssh.Client, error) {
-	// FIXME: Support default
-	if s.Port == 0 {
-		s.Port = 22
-	}
-
 	dialString := fmt.Sprintf("%s:%d", s.Host, s.Port)
 	logger.Yellow("ssh", "Connecting to %s as %s", dialString, s.Username)
 
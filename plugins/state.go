@@ -0,0 +1,102 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type (
+	// State is remembered for a monitor between runs of a StatefulAgent.
+	// The zero State is passed on the first run, and whenever the monitor
+	// has been changed, since the old state may not match the new
+	// arguments.
+	State struct {
+		// Time is the time of the run returning the state.
+		Time time.Time `json:"time" bson:"time"`
+		Data []byte    `json:"data" bson:"data"`
+	}
+
+	// Counters are monotonically increasing values, like the ones found in
+	// /proc/stat, used by agents calculating rates.
+	Counters map[string]uint64
+)
+
+var (
+	// MaxStateSize limits the size of the data saved by agents.
+	MaxStateSize = 64 * 1024
+
+	// SampleInterval is the time between the two runs of a stateful
+	// agent run without a previous state, like by the probe endpoint.
+	SampleInterval = time.Second
+
+	ErrorStateTooLarge error = errors.New("State too large")
+)
+
+// NewState encodes v as the state of a run at t.
+func NewState(t time.Time, v interface{}) (State, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return State{}, err
+	}
+
+	if len(data) > MaxStateSize {
+		return State{}, ErrorStateTooLarge
+	}
+
+	return State{Time: t, Data: data}, nil
+}
+
+// First returns true if there is no previous state.
+func (s State) First() bool {
+	return s.Time.IsZero() || len(s.Data) == 0
+}
+
+// Decode decodes the state into v.
+func (s State) Decode(v interface{}) error {
+	return json.Unmarshal(s.Data, v)
+}
+
+// Delta returns the increase of each counter since previous. Counters
+// missing from previous are left out. reset is true if a counter
+// decreased, which happens when the counters are reset by a reboot or a
+// restart, making the deltas meaningless.
+func (c Counters) Delta(previous Counters) (delta Counters, reset bool) {
+	delta = make(Counters)
+
+	for key, value := range c {
+		before, found := previous[key]
+		if !found {
+			continue
+		}
+
+		if value < before {
+			return nil, true
+		}
+
+		delta[key] = value - before
+	}
+
+	return delta, false
+}
+
+// Rates returns the per second increase of each counter since previous,
+// following the rules of Delta. reset is also true if no time has
+// elapsed.
+func (c Counters) Rates(previous Counters, elapsed time.Duration) (rates map[string]float64, reset bool) {
+	if elapsed <= 0 {
+		return nil, true
+	}
+
+	delta, reset := c.Delta(previous)
+	if reset {
+		return nil, true
+	}
+
+	rates = make(map[string]float64)
+	for key, value := range delta {
+		rates[key] = float64(value) / elapsed.Seconds()
+	}
+
+	return rates, false
+}
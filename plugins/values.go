@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseValue converts a string to a value suitable for JSON decoding into
+// a parameter of the given type.
+func parseValue(p Parameter, value string) (interface{}, error) {
+	switch p.Type {
+	case "string":
+		return value, nil
+	case "enum":
+		for _, v := range p.EnumValues {
+			if v == value {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.EnumValues, ", "))
+	case "bool":
+		return strconv.ParseBool(value)
+	case "int", "int8", "int16", "int32", "int64":
+		return strconv.ParseInt(value, 10, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return strconv.ParseUint(value, 10, 64)
+	case "float32", "float64":
+		return strconv.ParseFloat(value, 64)
+	case "time.Duration":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			// Accept plain nanoseconds like the JSON representation.
+			return strconv.ParseInt(value, 10, 64)
+		}
+		return int64(d), nil
+	}
+
+	return nil, fmt.Errorf("parameter %s of type %s cannot be given as a string", p.Name, p.Type)
+}
+
+// NewJobFromValues builds a job for an agent from string values, as found
+// in a query string. Values are validated against the parameters in the
+// agent description. Unknown names are rejected.
+func NewJobFromValues(agentId string, values map[string][]string) (*Job, error) {
+	desc, found := AvailableAgents()[agentId]
+	if !found {
+		return nil, fmt.Errorf("unknown agentId '%s'", agentId)
+	}
+
+	params := make(map[string]Parameter)
+	for _, p := range desc.Parameters {
+		params[p.Name] = p
+	}
+
+	arguments := make(map[string]interface{})
+	for name, v := range values {
+		p, found := params[name]
+		if !found {
+			return nil, fmt.Errorf("unknown parameter '%s' for agent '%s'", name, agentId)
+		}
+
+		if len(v) != 1 {
+			return nil, fmt.Errorf("parameter '%s' must be given exactly once", name)
+		}
+
+		value, err := parseValue(p, v[0])
+		if err != nil {
+			return nil, err
+		}
+
+		arguments[name] = value
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"agentId":   agentId,
+		"arguments": arguments,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{}
+	err = json.Unmarshal(data, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, job.Validate()
+}
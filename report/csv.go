@@ -0,0 +1,40 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 0, 64)
+}
+
+// WriteCSV writes the rows of a report as CSV. Durations are in seconds.
+func (r Report) WriteCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+
+	c.Write([]string{r.GroupBy, "monitors", "availability", "outages", "mttr_seconds", "downtime_seconds", "monitored_seconds"})
+
+	for _, row := range r.Rows {
+		availability := ""
+		if row.Availability != nil {
+			availability = strconv.FormatFloat(*row.Availability, 'f', 4, 64)
+		}
+
+		c.Write([]string{
+			row.Group,
+			strconv.Itoa(row.Monitors),
+			availability,
+			strconv.Itoa(row.Outages),
+			seconds(row.MTTR),
+			seconds(row.Downtime),
+			seconds(row.Monitored),
+		})
+	}
+
+	c.Flush()
+
+	return c.Error()
+}
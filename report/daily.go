@@ -0,0 +1,88 @@
+package report
+
+import (
+	"time"
+
+	"github.com/abrander/alerto/monitor"
+)
+
+type (
+	// Day is the combined availability of a set of monitors on a single
+	// day. Availability is nil if none of the monitors were checked.
+	Day struct {
+		Date         time.Time     `json:"date"`
+		Availability *float64      `json:"availability"`
+		Downtime     time.Duration `json:"downtime"`
+	}
+)
+
+// Daily returns the combined availability of monitors for each of the last
+// days, ending with today. Days start at midnight local time.
+func Daily(monitors []monitor.Monitor, days int, now time.Time) ([]Day, error) {
+	y, m, d := now.Date()
+	from := time.Date(y, m, d-days+1, 0, 0, 0, 0, now.Location())
+
+	result := make([]Day, days)
+	monitored := make([]time.Duration, days)
+	for i := range result {
+		result[i].Date = from.AddDate(0, 0, i)
+	}
+
+	maintenance := monitor.GetMaintenance(from, now)
+
+	for _, mon := range monitors {
+		changes, err := monitor.GetStateChanges(mon.Id, from, now)
+		if err != nil {
+			return nil, err
+		}
+
+		excluded := excludedIntervals(&mon, maintenance)
+
+		for i := range result {
+			start := result[i].Date
+			end := start.AddDate(0, 0, 1)
+			if end.After(now) {
+				end = now
+			}
+
+			s := changeStats(changes, start, end, excluded)
+			monitored[i] += s.monitored
+			result[i].Downtime += s.downtime
+		}
+	}
+
+	for i := range result {
+		if monitored[i] > 0 {
+			a := 100.0 * float64(monitored[i]-result[i].Downtime) / float64(monitored[i])
+			result[i].Availability = &a
+		}
+	}
+
+	return result, nil
+}
+
+// Uptime returns the combined availability of monitors from from to to, or
+// nil if none of them were checked.
+func Uptime(monitors []monitor.Monitor, from time.Time, to time.Time) (*float64, error) {
+	var monitored, downtime time.Duration
+
+	maintenance := monitor.GetMaintenance(from, to)
+
+	for _, mon := range monitors {
+		s, err := monitorStats(&mon, from, to, maintenance)
+		if err != nil {
+			return nil, err
+		}
+
+		monitored += s.monitored
+		downtime += s.downtime
+	}
+
+	if monitored == 0 {
+		return nil, nil
+	}
+
+	a := 100.0 * float64(monitored-downtime) / float64(monitored)
+
+	return &a, nil
+}
@@ -0,0 +1,290 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+)
+
+type (
+	// Options selects what to report on. Filter must be compiled.
+	Options struct {
+		From   time.Time
+		To     time.Time
+		Filter monitor.Filter
+
+		// GroupBy is "monitor", "host" or "label:<key>". Monitors without
+		// the label are grouped under an empty name.
+		GroupBy string
+	}
+
+	// Row is the availability of a single group. Availability is nil if
+	// none of the monitors were checked in the period.
+	Row struct {
+		Group        string        `json:"group"`
+		Monitors     int           `json:"monitors"`
+		Availability *float64      `json:"availability"`
+		Outages      int           `json:"outages"`
+		MTTR         time.Duration `json:"mttr"`
+		Downtime     time.Duration `json:"downtime"`
+		Monitored    time.Duration `json:"monitored"`
+	}
+
+	Report struct {
+		From    time.Time `json:"from"`
+		To      time.Time `json:"to"`
+		GroupBy string    `json:"groupBy"`
+		Rows    []Row     `json:"rows"`
+	}
+
+	interval struct {
+		start time.Time
+		end   time.Time
+	}
+
+	// stats are the numbers for a single monitor, which are summed for
+	// groups.
+	stats struct {
+		monitored time.Duration
+		downtime  time.Duration
+		outages   int
+		recovered int
+		repair    time.Duration
+	}
+)
+
+func (i interval) duration() time.Duration {
+	return i.end.Sub(i.start)
+}
+
+// overlap returns the part of i covered by the sorted, non-overlapping
+// intervals in list.
+func (i interval) overlap(list []interval) time.Duration {
+	var d time.Duration
+
+	for _, o := range list {
+		start := o.start
+		if i.start.After(start) {
+			start = i.start
+		}
+
+		end := o.end
+		if i.end.Before(end) {
+			end = i.end
+		}
+
+		if end.After(start) {
+			d += end.Sub(start)
+		}
+	}
+
+	return d
+}
+
+// merge sorts intervals and merges overlapping ones.
+func merge(list []interval) []interval {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].start.Before(list[j].start)
+	})
+
+	merged := []interval{}
+	for _, i := range list {
+		l := len(merged)
+		if l > 0 && !i.start.After(merged[l-1].end) {
+			if i.end.After(merged[l-1].end) {
+				merged[l-1].end = i.end
+			}
+		} else {
+			merged = append(merged, i)
+		}
+	}
+
+	return merged
+}
+
+// excludedIntervals returns the maintenance periods covering mon.
+func excludedIntervals(mon *monitor.Monitor, maintenance []monitor.Maintenance) []interval {
+	excluded := []interval{}
+	for _, m := range maintenance {
+		if m.Filter.MatchMonitor(mon) {
+			excluded = append(excluded, interval{m.Start, m.End})
+		}
+	}
+
+	return merge(excluded)
+}
+
+// monitorStats computes the numbers for a single monitor from its state
+// changes. Time in maintenance and in the Unknown state is not counted.
+func monitorStats(mon *monitor.Monitor, from time.Time, to time.Time, maintenance []monitor.Maintenance) (stats, error) {
+	changes, err := monitor.GetStateChanges(mon.Id, from, to)
+	if err != nil {
+		return stats{}, err
+	}
+
+	return changeStats(changes, from, to, excludedIntervals(mon, maintenance)), nil
+}
+
+// changeStats computes the numbers for the period from from to to. Changes
+// must be sorted by time and may extend beyond the period.
+func changeStats(changes []monitor.StateChange, from time.Time, to time.Time, excluded []interval) stats {
+	var s stats
+
+	state := monitor.Unknown
+	start := from
+
+	// end closes the segment from start to t in the current state.
+	end := func(t time.Time, next plugins.Status) {
+		segment := interval{start, t}
+		d := segment.duration() - segment.overlap(excluded)
+
+		if state != monitor.Unknown {
+			s.monitored += d
+		}
+
+		if state == plugins.Failed && d > 0 {
+			s.downtime += d
+			s.outages++
+
+			if next == plugins.Ok {
+				s.recovered++
+				s.repair += d
+			}
+		}
+
+		state = next
+		start = t
+	}
+
+	for _, change := range changes {
+		if change.Time.Before(from) {
+			state = change.To
+			continue
+		}
+
+		if !change.Time.Before(to) {
+			break
+		}
+
+		if state == plugins.Failed && change.To == plugins.Failed {
+			continue
+		}
+
+		end(change.Time, change.To)
+	}
+
+	if to.After(start) {
+		end(to, state)
+	}
+
+	return s
+}
+
+func groupName(groupBy string, mon *monitor.Monitor, hostNames map[bson.ObjectId]string) string {
+	switch {
+	case groupBy == "host":
+		return hostNames[mon.HostId]
+	case strings.HasPrefix(groupBy, "label:"):
+		return mon.Labels[strings.TrimPrefix(groupBy, "label:")]
+	}
+
+	if mon.Name != "" {
+		return mon.Name + " (" + mon.Id.Hex() + ")"
+	}
+
+	return mon.Id.Hex()
+}
+
+// Generate computes an availability report.
+func Generate(options Options) (Report, error) {
+	if options.GroupBy == "" {
+		options.GroupBy = "monitor"
+	}
+
+	report := Report{
+		From:    options.From,
+		To:      options.To,
+		GroupBy: options.GroupBy,
+		Rows:    []Row{},
+	}
+
+	if options.GroupBy != "monitor" && options.GroupBy != "host" && !strings.HasPrefix(options.GroupBy, "label:") {
+		return report, fmt.Errorf("cannot group by '%s'", options.GroupBy)
+	}
+
+	if !options.To.After(options.From) {
+		return report, monitor.ErrorInvalidPeriod
+	}
+
+	monitors, _, err := monitor.ListMonitors(monitor.ListOptions{Filter: options.Filter})
+	if err != nil {
+		return report, err
+	}
+
+	hostNames := make(map[bson.ObjectId]string)
+	for _, host := range monitor.GetAllHosts() {
+		hostNames[host.Id] = host.Name
+	}
+
+	maintenance := monitor.GetMaintenance(options.From, options.To)
+
+	groups := make(map[string]*stats)
+	counts := make(map[string]int)
+	names := []string{}
+
+	for _, mon := range monitors {
+		s, err := monitorStats(&mon, options.From, options.To, maintenance)
+		if err != nil {
+			return report, err
+		}
+
+		name := groupName(options.GroupBy, &mon, hostNames)
+
+		g, found := groups[name]
+		if !found {
+			g = &stats{}
+			groups[name] = g
+			names = append(names, name)
+		}
+
+		g.monitored += s.monitored
+		g.downtime += s.downtime
+		g.outages += s.outages
+		g.recovered += s.recovered
+		g.repair += s.repair
+		counts[name]++
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		g := groups[name]
+
+		row := Row{
+			Group:     name,
+			Monitors:  counts[name],
+			Outages:   g.outages,
+			Downtime:  g.downtime,
+			Monitored: g.monitored,
+		}
+
+		if g.monitored > 0 {
+			a := 100.0 * float64(g.monitored-g.downtime) / float64(g.monitored)
+			row.Availability = &a
+		}
+
+		if g.recovered > 0 {
+			row.MTTR = g.repair / time.Duration(g.recovered)
+		}
+
+		report.Rows = append(report.Rows, row)
+	}
+
+	return report, nil
+}
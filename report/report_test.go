@@ -0,0 +1,119 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+)
+
+var (
+	testFrom = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	testTo   = testFrom.Add(24 * time.Hour)
+)
+
+// at returns the time n hours into the test period.
+func at(n int) time.Time {
+	return testFrom.Add(time.Duration(n) * time.Hour)
+}
+
+func change(n int, to plugins.Status) monitor.StateChange {
+	return monitor.StateChange{Time: at(n), To: to}
+}
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		name     string
+		list     []interval
+		expected []interval
+	}{
+		{"empty", nil, []interval{}},
+		{"disjoint", []interval{{at(5), at(6)}, {at(1), at(2)}}, []interval{{at(1), at(2)}, {at(5), at(6)}}},
+		{"overlapping", []interval{{at(2), at(4)}, {at(1), at(3)}}, []interval{{at(1), at(4)}}},
+		{"contained", []interval{{at(1), at(10)}, {at(2), at(3)}}, []interval{{at(1), at(10)}}},
+		{"touching", []interval{{at(1), at(2)}, {at(2), at(3)}}, []interval{{at(1), at(3)}}},
+		{"bridged", []interval{{at(1), at(3)}, {at(5), at(6)}, {at(2), at(5)}}, []interval{{at(1), at(6)}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			merged := merge(c.list)
+			if !reflect.DeepEqual(merged, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, merged)
+			}
+		})
+	}
+}
+
+func TestChangeStats(t *testing.T) {
+	cases := []struct {
+		name     string
+		changes  []monitor.StateChange
+		excluded []interval
+		expected stats
+	}{
+		{
+			name:     "never checked",
+			expected: stats{},
+		},
+		{
+			name:     "first check in period",
+			changes:  []monitor.StateChange{change(6, plugins.Ok)},
+			expected: stats{monitored: 18 * time.Hour},
+		},
+		{
+			name:     "ok",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok)},
+			expected: stats{monitored: 24 * time.Hour},
+		},
+		{
+			name:     "outage",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok), change(2, plugins.Failed), change(3, plugins.Ok)},
+			expected: stats{monitored: 24 * time.Hour, downtime: time.Hour, outages: 1, recovered: 1, repair: time.Hour},
+		},
+		{
+			name:     "repeated failure",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok), change(2, plugins.Failed), change(3, plugins.Failed), change(4, plugins.Ok)},
+			expected: stats{monitored: 24 * time.Hour, downtime: 2 * time.Hour, outages: 1, recovered: 1, repair: 2 * time.Hour},
+		},
+		{
+			name:     "incident spanning start",
+			changes:  []monitor.StateChange{change(-2, plugins.Failed), change(1, plugins.Ok)},
+			expected: stats{monitored: 24 * time.Hour, downtime: time.Hour, outages: 1, recovered: 1, repair: time.Hour},
+		},
+		{
+			name:     "incident spanning end",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok), change(22, plugins.Failed), change(26, plugins.Ok)},
+			expected: stats{monitored: 24 * time.Hour, downtime: 2 * time.Hour, outages: 1},
+		},
+		{
+			name:     "maintenance overlapping outage",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok), change(2, plugins.Failed), change(4, plugins.Ok)},
+			excluded: []interval{{at(3), at(10)}},
+			expected: stats{monitored: 17 * time.Hour, downtime: time.Hour, outages: 1, recovered: 1, repair: time.Hour},
+		},
+		{
+			name:     "outage within maintenance",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok), change(2, plugins.Failed), change(3, plugins.Ok)},
+			excluded: []interval{{at(1), at(5)}},
+			expected: stats{monitored: 20 * time.Hour},
+		},
+		{
+			name:     "maintenance spanning start",
+			changes:  []monitor.StateChange{change(-1, plugins.Ok)},
+			excluded: []interval{{at(-5), at(2)}},
+			expected: stats{monitored: 22 * time.Hour},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := changeStats(c.changes, testFrom, testTo, c.excluded)
+			if s != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/logger"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/notify"
+)
+
+// periodStart returns the start of the period containing t.
+func periodStart(period string, t time.Time) (time.Time, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch period {
+	case "day":
+		return day, nil
+	case "week":
+		// Weeks start monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	}
+
+	return t, fmt.Errorf("unknown period '%s'", period)
+}
+
+// nextPeriod returns the start of the period following the one starting
+// at start.
+func nextPeriod(period string, start time.Time) time.Time {
+	switch period {
+	case "day":
+		return start.AddDate(0, 0, 1)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	}
+
+	return start.AddDate(0, 1, 0)
+}
+
+// Text returns a human readable summary of the report.
+func (r Report) Text() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Availability from %s to %s\n\n", r.From.Format(time.RFC3339), r.To.Format(time.RFC3339))
+
+	for _, row := range r.Rows {
+		availability := "-"
+		if row.Availability != nil {
+			availability = fmt.Sprintf("%.3f%%", *row.Availability)
+		}
+
+		fmt.Fprintf(&buf, "%s: %s, %d outages, %s downtime, MTTR %s\n", row.Group, availability, row.Outages, row.Downtime, row.MTTR)
+	}
+
+	return buf.String()
+}
+
+// Send generates the report for a schedule covering from to to and hands
+// it to the configured notifier.
+func Send(c config.Report, from time.Time, to time.Time) error {
+	filter := monitor.Filter{Labels: c.Labels}
+	err := filter.Compile()
+	if err != nil {
+		return err
+	}
+
+	r, err := Generate(Options{From: from, To: to, Filter: filter, GroupBy: c.GroupBy})
+	if err != nil {
+		return err
+	}
+
+	var csv bytes.Buffer
+	err = r.WriteCSV(&csv)
+	if err != nil {
+		return err
+	}
+
+	return notify.Send(c.Notifier, notify.Notification{
+		Subject: fmt.Sprintf("Alerto report %s for %s", c.Name, from.Format("2006-01-02")),
+		Text:    r.Text(),
+		Data:    r,
+		Attachments: []notify.Attachment{
+			{
+				Filename:    fmt.Sprintf("alerto-%s-%s.csv", c.Name, from.Format("2006-01-02")),
+				ContentType: "text/csv",
+				Content:     csv.Bytes(),
+			},
+		},
+	})
+}
+
+// schedule sends a report every time a period ends.
+func schedule(c config.Report) {
+	for {
+		start, err := periodStart(c.Period, time.Now())
+		if err != nil {
+			logger.Error("report", "%s: %s", c.Name, err.Error())
+			return
+		}
+
+		next := nextPeriod(c.Period, start)
+		time.Sleep(next.Sub(time.Now()))
+
+		err = Send(c, start, next)
+		if err != nil {
+			logger.Error("report", "%s: Error sending report: %s", c.Name, err.Error())
+		} else {
+			logger.Green("report", "%s: Report sent to %s", c.Name, c.Notifier)
+		}
+	}
+}
+
+// Start schedules all configured reports.
+func Start(reports []config.Report) {
+	for _, c := range reports {
+		go schedule(c)
+	}
+}
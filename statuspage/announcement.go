@@ -0,0 +1,105 @@
+package statuspage
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/alerto/logger"
+)
+
+type (
+	// Announcement is a message posted manually to a status page. It is
+	// shown until Until, or forever if Until is zero.
+	Announcement struct {
+		Id    bson.ObjectId `json:"id" bson:"_id"`
+		Page  string        `json:"page"`
+		Time  time.Time     `json:"time"`
+		Until time.Time     `json:"until"`
+		Title string        `json:"title"`
+		Text  string        `json:"text"`
+
+		// Severity is one of "info", "warning" and "critical".
+		Severity string `json:"severity"`
+	}
+)
+
+var (
+	announcementCollection *mgo.Collection
+
+	ErrorInvalidId       error = errors.New("Invalid id")
+	ErrorUnknownPage     error = errors.New("Unknown status page")
+	ErrorInvalidSeverity error = errors.New("Severity must be info, warning or critical")
+)
+
+func init() {
+	sess, err := mgo.Dial("127.0.0.1")
+	if err != nil {
+		logger.Error("statuspage", "Can't connect to mongo, go error %v", err)
+		os.Exit(1)
+	}
+
+	announcementCollection = sess.DB("alerto").C("announcements")
+	announcementCollection.EnsureIndex(mgo.Index{Key: []string{"page", "-time"}})
+}
+
+func AddAnnouncement(a *Announcement) error {
+	_, found := pageConfig(a.Page)
+	if !found {
+		return ErrorUnknownPage
+	}
+
+	switch a.Severity {
+	case "":
+		a.Severity = "info"
+	case "info", "warning", "critical":
+	default:
+		return ErrorInvalidSeverity
+	}
+
+	a.Id = bson.NewObjectId()
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+
+	invalidate(a.Page)
+
+	return announcementCollection.Insert(a)
+}
+
+func DeleteAnnouncement(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrorInvalidId
+	}
+
+	var a Announcement
+	err := announcementCollection.FindId(bson.ObjectIdHex(id)).One(&a)
+	if err != nil {
+		return err
+	}
+
+	invalidate(a.Page)
+
+	return announcementCollection.RemoveId(a.Id)
+}
+
+// GetAnnouncements returns the announcements for a page, newest first. If
+// all is false, expired announcements are left out.
+func GetAnnouncements(page string, all bool) ([]Announcement, error) {
+	announcements := []Announcement{}
+
+	query := bson.M{"page": page}
+	if !all {
+		query["$or"] = []bson.M{
+			{"until": time.Time{}},
+			{"until": bson.M{"$gt": time.Now()}},
+		}
+	}
+
+	err := announcementCollection.Find(query).Sort("-time").All(&announcements)
+
+	return announcements, err
+}
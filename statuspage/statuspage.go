@@ -0,0 +1,227 @@
+package statuspage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/abrander/alerto/config"
+	"github.com/abrander/alerto/monitor"
+	"github.com/abrander/alerto/plugins"
+	"github.com/abrander/alerto/report"
+)
+
+type (
+	// Page is what is shown publicly. It must never contain details about
+	// hosts, transports or the monitors themselves.
+	Page struct {
+		Name          string         `json:"name"`
+		Title         string         `json:"title"`
+		Status        string         `json:"status"`
+		Components    []Component    `json:"components"`
+		Announcements []Announcement `json:"announcements"`
+		Updated       time.Time      `json:"updated"`
+	}
+
+	Component struct {
+		Name   string       `json:"name"`
+		Status string       `json:"status"`
+		Uptime *float64     `json:"uptime"`
+		Days   []report.Day `json:"days"`
+	}
+)
+
+const (
+	// Days is the number of days of history shown.
+	Days = 90
+
+	// CacheTime is how long a rendered page is reused. Pages are public,
+	// and computing the history is not cheap.
+	CacheTime = time.Minute
+)
+
+// Component statuses, from best to worst.
+const (
+	Operational = "operational"
+	Maintenance = "maintenance"
+	Unknown     = "unknown"
+	Degraded    = "degraded"
+	Outage      = "outage"
+)
+
+var (
+	severity = map[string]int{
+		Operational: 0,
+		Maintenance: 1,
+		Unknown:     2,
+		Degraded:    3,
+		Outage:      4,
+	}
+
+	cacheLock sync.Mutex
+	cache     = make(map[string]Page)
+)
+
+func pageConfig(name string) (config.StatusPage, bool) {
+	for _, p := range config.Current.StatusPages {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return config.StatusPage{}, false
+}
+
+func invalidate(name string) {
+	cacheLock.Lock()
+	delete(cache, name)
+	cacheLock.Unlock()
+}
+
+func worst(a string, b string) string {
+	if severity[b] > severity[a] {
+		return b
+	}
+
+	return a
+}
+
+// componentMonitors returns the monitors making up a component. A
+// component without monitors or labels has no monitors.
+func componentMonitors(c config.Component) ([]monitor.Monitor, error) {
+	if len(c.Monitors) == 0 && c.Labels == "" {
+		return []monitor.Monitor{}, nil
+	}
+
+	filter := monitor.Filter{
+		MonitorIds: c.Monitors,
+		Labels:     c.Labels,
+	}
+
+	err := filter.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	monitors, _, err := monitor.ListMonitors(monitor.ListOptions{Filter: filter})
+
+	return monitors, err
+}
+
+// Status derives the status of a component from its monitors. Paused
+// monitors are ignored.
+func Status(monitors []monitor.Monitor, now time.Time) string {
+	total := 0
+	failed := 0
+	maintenance := 0
+	unknown := 0
+
+	for _, mon := range monitors {
+		if mon.Paused {
+			continue
+		}
+
+		total++
+
+		switch {
+		case monitor.InMaintenance(&mon, now):
+			maintenance++
+		case mon.LastCheck.IsZero():
+			unknown++
+		case mon.LastResult.Status != plugins.Ok:
+			failed++
+		}
+	}
+
+	switch {
+	case total == 0 || unknown == total:
+		return Unknown
+	case failed == total:
+		return Outage
+	case failed > 0:
+		return Degraded
+	case maintenance > 0:
+		return Maintenance
+	}
+
+	return Operational
+}
+
+func render(c config.StatusPage, now time.Time) (Page, error) {
+	page := Page{
+		Name:       c.Name,
+		Title:      c.Title,
+		Status:     Operational,
+		Components: []Component{},
+		Updated:    now,
+	}
+
+	if page.Title == "" {
+		page.Title = c.Name
+	}
+
+	for _, cc := range c.Components {
+		monitors, err := componentMonitors(cc)
+		if err != nil {
+			return page, err
+		}
+
+		days, err := report.Daily(monitors, Days, now)
+		if err != nil {
+			return page, err
+		}
+
+		component := Component{
+			Name:   cc.Name,
+			Status: Status(monitors, now),
+			Days:   days,
+		}
+
+		var up, total float64
+		for _, d := range days {
+			if d.Availability != nil {
+				up += *d.Availability
+				total++
+			}
+		}
+
+		if total > 0 {
+			uptime := up / total
+			component.Uptime = &uptime
+		}
+
+		page.Status = worst(page.Status, component.Status)
+		page.Components = append(page.Components, component)
+	}
+
+	var err error
+	page.Announcements, err = GetAnnouncements(c.Name, false)
+
+	return page, err
+}
+
+// Get returns the named status page.
+func Get(name string) (Page, error) {
+	c, found := pageConfig(name)
+	if !found {
+		return Page{}, ErrorUnknownPage
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	now := time.Now()
+
+	page, found := cache[name]
+	if found && now.Sub(page.Updated) < CacheTime {
+		return page, nil
+	}
+
+	page, err := render(c, now)
+	if err != nil {
+		return page, err
+	}
+
+	cache[name] = page
+
+	return page, nil
+}
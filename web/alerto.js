@@ -61,6 +61,44 @@ Alerto.Factory.MonitorService = function($resource) {
 
 alerto.factory('MonitorService', Alerto.Factory.MonitorService);
 
+/**
+ * Opens a login dialog whenever the API answers 401 Unauthorized.
+ * @constructor
+ * @param {*} $q
+ * @param {*} $injector
+ * @ngInject
+ */
+Alerto.Factory.AuthInterceptor = function($q, $injector) {
+	var loginOpen = false;
+
+	return {
+		responseError: function(response) {
+			if (response.status == 401 && !loginOpen) {
+				loginOpen = true;
+
+				var modalInstance = $injector.get('$uibModal').open({
+					animation: true,
+					templateUrl: 'loginModalTemplate',
+					controller: 'LoginController',
+					backdrop: 'static',
+					keyboard: false
+				});
+				modalInstance.result.then(function() {
+					window.location.reload();
+				});
+			}
+
+			return $q.reject(response);
+		}
+	};
+};
+
+alerto.factory('AuthInterceptor', Alerto.Factory.AuthInterceptor);
+
+alerto.config(function($httpProvider) {
+	$httpProvider.interceptors.push('AuthInterceptor');
+});
+
 /**
  * @ngInject
  * @constructor
@@ -72,6 +110,26 @@ Alerto.Controller.MainController = function(HostService, MonitorService, $http,
 	this.monitors = MonitorService.query();
 	this.uptime = 0;
 
+	this.incidents = [];
+	$http.get('/incident/?open=true').then(function(response) {
+		self.incidents = response.data;
+	});
+
+	this.me = null;
+	$http.get('/me').then(function(response) {
+		self.me = response.data;
+		connect();
+	});
+
+	/**
+	 * @expose
+	 */
+	this.logout = function() {
+		$http.post('/logout').then(function() {
+			window.location.reload();
+		});
+	};
+
 	this.agents = {};
 	$http.get('/agent/').then(function(response) {
 		self.agents = response.data;
@@ -110,7 +168,44 @@ Alerto.Controller.MainController = function(HostService, MonitorService, $http,
 	 * @expose
 	 */
 	this.deleteHost = function(id) {
-		HostService.delete({id: id});
+		HostService.delete({id: id}).$promise.catch(function(response) {
+			if (response.status == 409 && confirm('Host is in use. Delete its monitors as well?')) {
+				HostService.delete({id: id, cascade: true});
+			}
+		});
+	};
+
+	/**
+	 * @expose
+	 * @param {string} id
+	 * @param {string} command One of run, pause, resume or ack
+	 */
+	this.monitorCommand = function(id, command) {
+		var body = {};
+		if (command == 'ack')
+			body.comment = prompt('Comment');
+
+		$http.post('/monitor/' + id + '/' + command, body);
+	};
+
+	/**
+	 * @expose
+	 * @param {string} id
+	 */
+	this.addIncidentNote = function(id) {
+		var text = prompt('Note');
+		if (text)
+			$http.post('/incident/' + id + '/note', {text: text});
+	};
+
+	this.expanded = {};
+
+	/**
+	 * @expose
+	 * @param {string} id
+	 */
+	this.toggleDetails = function(id) {
+		self.expanded[id] = !self.expanded[id];
 	};
 
 	/**
@@ -157,15 +252,43 @@ Alerto.Controller.MainController = function(HostService, MonitorService, $http,
 		url += 'ws://';
 	url += window.location.host + '/ws';
 
-	var socket = new WebSocket(url);
+	var socket = null;
+	var lastSeq = 0;
 
-	socket.onmessage = function(msg) {
+	var connect = function() {
+		var u = url;
+		if (lastSeq > 0)
+			u += '?since=' + lastSeq;
+
+		socket = new WebSocket(u);
+		socket.onmessage = onmessage;
+		socket.onclose = function() {
+			// Reconnect and replay what we missed
+			setTimeout(connect, 1000);
+		};
+	};
+
+	var onmessage = function(msg) {
 		var message = JSON.parse(msg.data);
+
+		if (message.seq)
+			lastSeq = message.seq;
+
 		$scope.$apply(function() {
 
 			switch (message.type) {
 				case 'status':
 					self.uptime = message.payload.uptime;
+					if (lastSeq == 0)
+						lastSeq = message.payload.seq;
+					break;
+				case 'resync':
+					lastSeq = message.payload;
+					self.hosts = HostService.query();
+					self.monitors = MonitorService.query();
+					$http.get('/incident/?open=true').then(function(response) {
+						self.incidents = response.data;
+					});
 					break;
 				case 'hostadd':
 					self.hosts.push(message.payload);
@@ -201,6 +324,28 @@ Alerto.Controller.MainController = function(HostService, MonitorService, $http,
 						}
 					});
 					break;
+				case 'incidentopen':
+					self.incidents.unshift(message.payload);
+					break;
+				case 'incidentchange':
+					self.incidents.forEach(function(incident, index) {
+						if (incident.id == message.payload.id) {
+							self.incidents[index] = message.payload;
+						}
+					});
+					break;
+				case 'incidentclose':
+					self.incidents.forEach(function(incident, index) {
+						if (incident.id == message.payload.id) {
+							self.incidents.splice(index, 1);
+						}
+					});
+					break;
+				case 'statechange':
+				case 'maintenanceadd':
+				case 'maintenancedelete':
+				case 'reply':
+					break;
 				default:
 					console.warn("Unsupported message type: " + message.type);
 					break;
@@ -211,6 +356,25 @@ Alerto.Controller.MainController = function(HostService, MonitorService, $http,
 
 alerto.controller('MainController', Alerto.Controller.MainController);
 
+/**
+ * @ngInject
+ * @constructor
+ */
+Alerto.Controller.LoginController = function($scope, $http, $uibModalInstance) {
+	$scope.credentials = {};
+	$scope.error = '';
+
+	$scope.ok = function() {
+		$http.post('/login', $scope.credentials).then(function() {
+			$uibModalInstance.close();
+		}, function() {
+			$scope.error = 'Wrong username or password';
+		});
+	};
+};
+
+alerto.controller('LoginController', Alerto.Controller.LoginController);
+
 /**
  * @ngInject
  * @constructor
@@ -249,11 +413,16 @@ Alerto.Controller.NewMonitorController = function($scope, $uibModalInstance, age
 	$scope.agent = agent;
 	$scope.hosts = hosts;
 	$scope.newMonitor = {
-		agent: {},
+		agent: {arguments: {}},
 		interval: 30,
 		hostId: '000000000000000000000000'
 	};
 
+	agent.parameters.forEach(function(par) {
+		if (par.default !== undefined)
+			$scope.newMonitor.agent.arguments[par.name] = par.default;
+	});
+
 	$scope.ok = function() {
 		$uibModalInstance.close($scope.newMonitor);
 	};
@@ -315,6 +484,60 @@ Alerto.Filter.GoDuration = function() {
 
 alerto.filter('goDuration', Alerto.Filter.GoDuration);
 
+/**
+ * Formats a measurement according to its unit. Values are in base units.
+ * @ngInject
+ * @constructor
+ */
+Alerto.Filter.Measurement = function(goDurationFilter) {
+	var formatBytes = function(b) {
+		var prefixes = ['', 'Ki', 'Mi', 'Gi', 'Ti', 'Pi', 'Ei'];
+		var i = 0;
+
+		while (Math.abs(b) >= 1024 && i < prefixes.length - 1) {
+			b /= 1024;
+			i++;
+		}
+
+		if (i == 0)
+			return b + ' B';
+
+		return b.toFixed(1) + ' ' + prefixes[i] + 'B';
+	};
+
+	/**
+	 * @param {Object} m
+	 * @return {string}
+	 */
+	var filter = function(m) {
+		if (m == undefined)
+			return '-';
+
+		// Results recorded before measurements had units.
+		if (typeof m == 'number')
+			return String(m);
+
+		switch (m.unit) {
+			case 's':
+				return goDurationFilter(Math.round(m.value * 1000000000));
+			case 'bytes':
+				return formatBytes(m.value);
+			case 'bytes/s':
+				return formatBytes(m.value) + '/s';
+			case '%':
+				return m.value.toFixed(1) + '%';
+			case '/s':
+				return m.value + '/s';
+		}
+
+		return String(m.value);
+	};
+
+	return filter;
+};
+
+alerto.filter('measurement', Alerto.Filter.Measurement);
+
 /**
  * @ngInject
  * @constructor